@@ -1,12 +1,19 @@
 package main
 
 import (
+  "flag"
   "fmt"
   "log"
   "io/ioutil"
   "math"
+  "os"
+  "os/signal"
   "runtime"
+  "strconv"
   "strings"
+  "sync"
+  "sync/atomic"
+  "syscall"
   "time"
 
   "github.com/tarm/serial"
@@ -22,10 +29,78 @@ const (
   hzC                = 523.25
 )
 
+var (
+  deviceFlag = flag.String("device", "", "Serial device for the Arduino, e.g. /dev/ttyACM0 or COM3. Found automatically if not set.")
+  baudFlag   = flag.Int("baud", 9600, "Baud rate of the Arduino serial connection.")
+  formatFlag = flag.String("format", "byte", "How samples are sent: byte (8 bit), uint16le (little-endian pairs) or line (ASCII integers, one per line).")
+  bitsFlag   = flag.Int("bits", 10, "Resolution of uint16le and line samples, used to scale them to [0, 1).")
+  rootFlag   = flag.Float64("root", hzC, "Frequency in Hz of the lowest tone.")
+  scaleFlag  = flag.String("scale", "0,2,4,5,7,9,11,12", "Comma separated semitones above the root to snap tones to.")
+)
+
+// ToneMap snaps values in [0, 1] to notes of a scale, lowest values to the first semitone.
+type ToneMap struct {
+  // Frequency in Hz that the semitones are relative to.
+  Root float64
+  // Semitones above the root for each tone, e.g. {0, 2, 4, 5, 7, 9, 11, 12} for a major scale.
+  Semitones []int
+}
+
+// Hz returns the frequency of the tone for a value in [0, 1]. Values are split evenly
+// between the tones, with values out of range snapped to the lowest or highest tone.
+func (tm ToneMap) Hz(value float64) float64 {
+  toneOffset := int(value * float64(len(tm.Semitones)))
+  if toneOffset < 0 {
+    toneOffset = 0
+  } else if toneOffset >= len(tm.Semitones) {
+    toneOffset = len(tm.Semitones) - 1
+  }
+  return tm.Root * math.Pow(2.0, float64(tm.Semitones[toneOffset]) / 12.0)
+}
+
+// parseToneMap builds the tone map from the -root and -scale flags.
+func parseToneMap() ToneMap {
+  tm := ToneMap{*rootFlag, nil}
+  for _, part := range strings.Split(*scaleFlag, ",") {
+    semitone, err := strconv.Atoi(strings.TrimSpace(part))
+    if err != nil {
+      log.Fatalf("Invalid -scale semitone %q: %v", part, err)
+    }
+    tm.Semitones = append(tm.Semitones, semitone)
+  }
+  return tm
+}
+
+// Player turns the latest sample value into a tone. The serial reader, tone generator,
+// audio output and shutdown handler all run in different goroutines, so the shared state
+// is atomic or signalled by closing channels.
 type Player struct {
-  currentValue float64
-  started bool
-  running bool
+  // Bits of the latest float64 value in [0, 1], see SetValue.
+  currentValue atomic.Uint64
+  started atomic.Bool
+  tones ToneMap
+  // Closed by Stop, to end the tone stream.
+  done chan bool
+  stopOnce sync.Once
+  // Closed once the tone generator has closed its channel and returned.
+  stopped chan bool
+  // Closed once the audio output has finished playing, if it was started.
+  played chan bool
+}
+
+// NewPlayer creates a player for the given tones, which is silent until started.
+func NewPlayer(tones ToneMap) *Player {
+  return &Player{tones: tones, done: make(chan bool), stopped: make(chan bool), played: make(chan bool)}
+}
+
+// SetValue changes the value that the tone is generated from.
+func (player *Player) SetValue(value float64) {
+  player.currentValue.Store(math.Float64bits(value))
+}
+
+// Value is the latest value given to SetValue.
+func (player *Player) Value() float64 {
+  return math.Float64frombits(player.currentValue.Load())
 }
 
 // findArduino looks for the file that represents the Arduino
@@ -34,6 +109,9 @@ type Player struct {
 // Arduino, otherwise an empty string if unable to find
 // something that 'looks' like an Arduino device.
 func findArduino() string {
+  if runtime.GOOS == "windows" {
+    return findComPort()
+  }
   contents, _ := ioutil.ReadDir("/dev")
 
   // Look for what is mostly likely the Arduino device
@@ -51,36 +129,87 @@ func findArduino() string {
   return ""
 }
 
+// findComPort returns the first COM port on Windows that can be opened,
+// or an empty string if none can. Windows has no /dev to list devices from.
+func findComPort() string {
+  for i := 1; i <= 32; i++ {
+    name := fmt.Sprintf("COM%d", i)
+    if port, err := serial.OpenPort(&serial.Config{Name: name, Baud: *baudFlag}); err == nil {
+      port.Close()
+      fmt.Printf("Arduino serial at %s\n", name)
+      return name
+    }
+  }
+  return ""
+}
+
 func main() {
+  flag.Parse()
   runtime.GOMAXPROCS(2)
 
+  device := *deviceFlag
+  if device == "" {
+    device = findArduino()
+  }
+  if device == "" {
+    log.Fatal("No Arduino found, pass its serial device with -device")
+  }
+
   fmt.Printf("Open the serial cable...\n")
-  port, err := serial.OpenPort(&serial.Config{Name: findArduino(), Baud: 9600})
+  port, err := serial.OpenPort(&serial.Config{Name: device, Baud: *baudFlag})
   if err != nil {
     log.Fatal(err)
   }
   time.Sleep(1 * time.Second)
 
   fmt.Printf("Generate the tone definition...\n")
-  player := &Player{}
+  player := NewPlayer(parseToneMap())
   toPlay := s.SumSounds(
     s.NewHzFromChannel(player.sampledToneGenerator()),
-    s.NewSineWave(hzC / 2.0),
+    s.NewSineWave(player.tones.Root / 2.0),
   )
 
+  // On Ctrl-C or kill, stop the tones and release the serial port before exiting.
+  interrupts := make(chan os.Signal, 1)
+  signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+  go func() {
+    <-interrupts
+    fmt.Printf("Shutting down...\n")
+    player.Stop()
+    port.Close()
+    os.Exit(0)
+  }()
+
   buf := make([]byte, 128)
+  // Bytes read but not yet parsed, e.g. the start of a sample split between reads.
+  pending := []byte{}
   startTime, readCount := time.Now(), 0
   for {
-    if _, err := port.Read(buf); err != nil {
+    n, err := port.Read(buf)
+    if err != nil {
+      log.Fatalf("Serial read failed: %v", err)
+    }
+    pending = append(pending, buf[:n]...)
+
+    for {
+      value, used := parseSample(pending, len(pending))
+      if used == 0 {
+        break
+      }
+      pending = pending[used:]
+      if math.IsNaN(value) {
+        continue
+      }
+
       if readCount == 0 {
         startTime = time.Now()
         player.Start(toPlay)
       }
       readCount++
-  
-      player.currentValue = float64(buf[0]) / 256.0
+
+      player.SetValue(value)
       if readCount % 100000 == 0 {
-        fmt.Printf("Value = %f\n", player.currentValue)
+        fmt.Printf("Value = %f\n", value)
       }
       if readCount % 1000000 == 0 {
         seconds := time.Since(startTime).Seconds()
@@ -91,50 +220,96 @@ func main() {
   }
 }
 
+// parseSample reads the first sample from buf[:n], in the format given by -format, and scales
+// it to [0, 1). Returns the value and how many bytes it used, or 0 bytes if buf doesn't yet
+// hold a full sample. A line that isn't a valid integer is skipped, returning NaN.
+func parseSample(buf []byte, n int) (float64, int) {
+  scale := math.Pow(2, float64(*bitsFlag))
+  switch *formatFlag {
+  case "byte":
+    if n < 1 {
+      return 0, 0
+    }
+    return float64(buf[0]) / 256.0, 1
+
+  case "uint16le":
+    if n < 2 {
+      return 0, 0
+    }
+    return math.Min(float64(uint16(buf[0]) | uint16(buf[1]) << 8) / scale, 1), 2
+
+  case "line":
+    end := strings.IndexByte(string(buf[:n]), '\n')
+    if end < 0 {
+      return 0, 0
+    }
+    value, err := strconv.Atoi(strings.TrimSpace(string(buf[:end])))
+    if err != nil {
+      return math.NaN(), end + 1
+    }
+    return math.Min(math.Max(float64(value) / scale, 0), 1), end + 1
+
+  default:
+    log.Fatalf("Unknown sample format %q", *formatFlag)
+    return 0, 0
+  }
+}
+
 // Start the player by initializing state and playing the tone
 func (player *Player) Start(sound s.Sound) {
   fmt.Printf("Player starting...\n")
 
-  player.running = true
-  player.started = true
+  player.started.Store(true)
   go func() {
+    defer close(player.played)
     output.Play(sound)
   }()
 }
 
+// Stop ends the tone stream, and waits for the generator to finish and, if playback was
+// started, for the audio output to finish playing what it has. Safe to call more than once.
+func (player *Player) Stop() {
+  player.stopOnce.Do(func() { close(player.done) })
+  <-player.stopped
+  if player.started.Load() {
+    <-player.played
+  }
+}
+
 // Generate a stream of tone values at the correct sample rate, 
-// based off player.currentValue as [0, 1]
+// based off player.Value() as [0, 1], until the player is stopped.
 func (player *Player) sampledToneGenerator() <-chan float64{
   samples := make(chan float64)
 
   go func() {
-    player.running = true
+    defer close(player.stopped)
+    defer close(samples)
     atNano := float64(time.Now().UnixNano())
 
     ticker := time.NewTicker(tickerDuration)
     defer ticker.Stop()
 
-    for now := range ticker.C {
-      nowNano := float64(now.UnixNano())
-
-      for ; atNano < nowNano && player.running; atNano += nsPerCycle {
-        currentValue := player.currentValue
-        if !player.started {
-          samples <- 0
-        } else if player.running {
-          // Snap to tones in a C major scale.
-          toneOffset := int(currentValue * 8)
-          toneValue := []int{0, 2, 4, 5, 7, 9, 11, 12}[toneOffset]
-          currentSemitone := math.Pow(2.0, float64(toneValue) / 12.0)
-          samples <- hzC * currentSemitone
+    for {
+      select {
+      case <-player.done:
+        return
+      case now := <-ticker.C:
+        nowNano := float64(now.UnixNano())
+        for ; atNano < nowNano; atNano += nsPerCycle {
+          tone := 0.0
+          if player.started.Load() {
+            // Snap to tones in the player's scale.
+            tone = player.tones.Hz(player.Value())
+          }
+          // Nothing reads samples until playback starts, so don't block stopping on that.
+          select {
+          case samples <- tone:
+          case <-player.done:
+            return
+          }
         }
       }
-
-      if !player.running { 
-        break
-      }
     }
-    close(samples)
   }()
 
   return samples
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RunBatch grades every subject and series combination in config, as gradeSubjectSeries does
+// for one: each series is trained on, then graded on the next series in the list, wrapping
+// around at the end. At most concurrency combinations are run at once, each loading its own
+// recordings, so memory use grows with concurrency. Results are in the order of subjects
+// then series. If any combination fails, the others still run, and the first error is
+// returned alongside all the results that succeeded.
+func RunBatch(config DataConfig, subjects []int, series []int, concurrency int) ([]Result, error) {
+	if len(series) < 2 {
+		panic("RunBatch needs at least two series, to train on one and grade on another")
+	}
+	if concurrency < 1 {
+		panic("RunBatch needs a concurrency of at least one")
+	}
+
+	jobResults := make([][]Result, len(subjects)*len(series))
+	jobErrors := make([]error, len(subjects)*len(series))
+	running := make(chan bool, concurrency)
+	var wg sync.WaitGroup
+	for i, subject := range subjects {
+		for j, trainSeries := range series {
+			wg.Add(1)
+			go func(job int, subject int, trainSeries int, testSeries int) {
+				defer wg.Done()
+				running <- true
+				defer func() { <-running }()
+				results, err := gradeSubjectSeries(config, subject, trainSeries, testSeries)
+				if err != nil {
+					err = fmt.Errorf("subject %d, series %d -> %d: %v", subject, trainSeries, testSeries, err)
+				}
+				jobResults[job], jobErrors[job] = results, err
+			}(i*len(series)+j, subject, trainSeries, series[(j+1)%len(series)])
+		}
+	}
+	wg.Wait()
+
+	results := []Result{}
+	var firstErr error
+	for job := range jobResults {
+		results = append(results, jobResults[job]...)
+		if firstErr == nil {
+			firstErr = jobErrors[job]
+		}
+	}
+	return results, firstErr
+}
+
+// writeBatch runs RunBatch, prints a line for each result, and writes the results to a CSV file.
+// Results are written even if some combinations failed, in which case the first error is
+// still returned.
+func writeBatch(filename string, config DataConfig, subjects []int, series []int, concurrency int) error {
+	results, batchErr := RunBatch(config, subjects, series, concurrency)
+	for _, r := range results {
+		printResult(r)
+	}
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := WriteResults(out, results); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return batchErr
+}
+
+// parseInts parses a comma separated list of integers, exiting if any are invalid.
+func parseInts(list string) []int {
+	result := []int{}
+	for _, part := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			fmt.Printf("Invalid number %q: %v\n", part, err)
+			os.Exit(1)
+		}
+		result = append(result, value)
+	}
+	return result
+}
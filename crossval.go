@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/padster/eego/grading"
+	"github.com/padster/eego/trees"
+)
+
+// CrossValidate estimates how well a forest detects one event from one EEG channel, by
+// k-fold cross validation over whole series (see grading.GroupKFold).
+// data[s] and events[s] are the channels for series s. For each fold, train is given the
+// dataId samples and eventId events of all other series joined end to end, with weights for
+// TrainWeighted (see joinSeries) that skip the frames of frameSize samples which straddle two
+// series, and the resulting forest classifies each held-out series separately.
+// Returns the ROC AUC for each fold, NaN for folds that can't be scored, e.g. when the
+// held-out series never contain the event, along with an error saying why for each of those.
+func CrossValidate(data [][]Channel, events [][]Channel, folds int, frameSize int, dataId string, eventId string,
+	train func(samples []int, expected []int, weights []float64) *trees.Forest) ([]float64, error) {
+	if len(data) != len(events) {
+		panic("CrossValidate requires data and events for every series")
+	}
+
+	lengths := make([]int, len(data), len(data))
+	for s := range data {
+		lengths[s] = len(channelSamples(data[s], dataId))
+	}
+
+	scores, foldErrors := make([]float64, folds, folds), []error{}
+	for fold, heldOut := range grading.GroupKFold(lengths, folds) {
+		isHeldOut := map[int]bool{}
+		for _, s := range heldOut {
+			isHeldOut[s] = true
+		}
+
+		samples, expected := [][]int{}, [][]int{}
+		for s := range data {
+			if !isHeldOut[s] {
+				samples = append(samples, channelSamples(data[s], dataId))
+				expected = append(expected, channelSamples(events[s], eventId))
+			}
+		}
+		forest := train(joinSeries(samples, expected, frameSize))
+
+		actual, predictions := []int{}, []float64{}
+		for _, s := range heldOut {
+			predictions = append(predictions, forest.Classify(channelSamples(data[s], dataId))...)
+			actual = append(actual, channelSamples(events[s], eventId)...)
+		}
+		score, err := grading.RocAucScore(actual, predictions)
+		if err != nil {
+			foldErrors = append(foldErrors, fmt.Errorf("fold %d can't be scored: %w", fold, err))
+			score = math.NaN()
+		}
+		scores[fold] = score
+	}
+	return scores, errors.Join(foldErrors...)
+}
+
+// LeaveOneSubjectOut estimates how well a forest detects one event from one EEG channel on
+// subjects it has never seen. For each subject, train is given the dataId samples and
+// eventId events of every series of every other subject, joined end to end and weighted as
+// for CrossValidate, so no training frame mixes two recordings, and the resulting forest
+// classifies each series of the held-out subject separately. loader returns the channels
+// for each series of a subject, as for CrossValidate.
+// Returns the ROC AUC for each subject, NaN for subjects that can't be scored, along with an
+// error saying why for each of those.
+func LeaveOneSubjectOut(subjects []int, loader func(subj int) (data, events [][]Channel), frameSize int,
+	dataId string, eventId string, train func(samples []int, expected []int, weights []float64) *trees.Forest) ([]float64, error) {
+	if len(subjects) < 2 {
+		panic("LeaveOneSubjectOut needs at least two subjects")
+	}
+
+	// Only keep the channels that are used, rather than every subject's full recordings.
+	samples := make([][][]int, len(subjects), len(subjects))
+	expected := make([][][]int, len(subjects), len(subjects))
+	for i, subject := range subjects {
+		data, events := loader(subject)
+		if len(data) != len(events) {
+			panic("LeaveOneSubjectOut requires data and events for every series")
+		}
+		for s := range data {
+			samples[i] = append(samples[i], channelSamples(data[s], dataId))
+			expected[i] = append(expected[i], channelSamples(events[s], eventId))
+		}
+	}
+
+	scores, subjectErrors := make([]float64, len(subjects), len(subjects)), []error{}
+	for heldOut, subject := range subjects {
+		trainSamples, trainExpected := [][]int{}, [][]int{}
+		for i := range subjects {
+			if i != heldOut {
+				trainSamples = append(trainSamples, samples[i]...)
+				trainExpected = append(trainExpected, expected[i]...)
+			}
+		}
+		forest := train(joinSeries(trainSamples, trainExpected, frameSize))
+
+		actual, predictions := []int{}, []float64{}
+		for s := range samples[heldOut] {
+			predictions = append(predictions, forest.Classify(samples[heldOut][s])...)
+			actual = append(actual, expected[heldOut][s]...)
+		}
+		score, err := grading.RocAucScore(actual, predictions)
+		if err != nil {
+			subjectErrors = append(subjectErrors, fmt.Errorf("subject %d can't be scored: %w", subject, err))
+			score = math.NaN()
+		}
+		scores[heldOut] = score
+	}
+	return scores, errors.Join(subjectErrors...)
+}
+
+// joinSeries joins the samples and expected values of several series end to end, ready for
+// TrainWeighted. The frames ending in the first frameSize - 1 samples of each series would
+// include samples from the end of the previous series, so they are given a weight of 0 and
+// don't count towards any split, and every other frame has a weight of 1.
+func joinSeries(samples [][]int, expected [][]int, frameSize int) ([]int, []int, []float64) {
+	if frameSize < 1 {
+		panic("joinSeries requires a positive frame size")
+	}
+	joined, joinedExpected, weights := []int{}, []int{}, []float64{}
+	for s := range samples {
+		if len(samples[s]) != len(expected[s]) {
+			panic("joinSeries requires samples and expected values to be the same size")
+		}
+		joined = append(joined, samples[s]...)
+		joinedExpected = append(joinedExpected, expected[s]...)
+		for i := range samples[s] {
+			weight := 1.0
+			if i < frameSize-1 {
+				weight = 0.0
+			}
+			weights = append(weights, weight)
+		}
+	}
+	return joined, joinedExpected, weights
+}
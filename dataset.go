@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Dataset is a single recording: EEG channels, and event channels flagging (as 0 or 1) what
+// happened at each sample, all with the same number of samples.
+type Dataset interface {
+	Channels() []Channel
+	Events() []Channel
+	// SampleRate is how many samples were recorded each second, in Hz.
+	SampleRate() float64
+}
+
+// DatasetOpener loads a Dataset from a data file, and a separate events file for formats
+// that keep them apart. eventsFile may be empty for formats which store events in the data.
+type DatasetOpener func(dataFile string, eventsFile string) (Dataset, error)
+
+// Openers for each registered format, by lower case file extension.
+var datasetOpeners = map[string]DatasetOpener{}
+
+// RegisterDatasetFormat makes OpenDataset use opener for data files with this extension,
+// e.g. ".edf". Registering the same extension twice panics.
+func RegisterDatasetFormat(extension string, opener DatasetOpener) {
+	extension = strings.ToLower(extension)
+	if _, exists := datasetOpeners[extension]; exists {
+		panic("Dataset format already registered for " + extension)
+	}
+	datasetOpeners[extension] = opener
+}
+
+// OpenDataset loads a recording using the opener registered for the data file's extension.
+func OpenDataset(dataFile string, eventsFile string) (Dataset, error) {
+	extension := strings.ToLower(filepath.Ext(dataFile))
+	opener, ok := datasetOpeners[extension]
+	if !ok {
+		return nil, fmt.Errorf("no dataset format registered for %s files: %s", extension, dataFile)
+	}
+	return opener(dataFile, eventsFile)
+}
+
+// Open loads the training recording and events for a subject and series.
+func (dc DataConfig) Open(subject int, series int) (Dataset, error) {
+	return OpenDataset(dc.DataFile(subject, series, false), dc.EventsFile(subject, series))
+}
+
+// CSVSampleRate is the sample rate of the grasp-and-lift CSV files, which don't record it.
+const CSVSampleRate = 500.0
+
+func init() {
+	RegisterDatasetFormat(".csv", func(dataFile string, eventsFile string) (Dataset, error) {
+		return NewCSVDataset(dataFile, eventsFile, CSVSampleRate)
+	})
+}
+
+// loadedDataset is a recording which has been loaded into memory.
+type loadedDataset struct {
+	channels   []Channel
+	events     []Channel
+	sampleRate float64
+}
+
+// NewCSVDataset loads the EEG channels and event channels from separate CSV files, each with
+// a header row of channel names then one row per sample, as loaded by loadChannels. Test
+// recordings have no events, so eventsFile may be empty, in which case Events is nil.
+func NewCSVDataset(dataFile string, eventsFile string, sampleRate float64) (Dataset, error) {
+	channels, err := loadChannels(dataFile)
+	if err != nil {
+		return nil, err
+	}
+	var events []Channel
+	if eventsFile != "" {
+		if events, err = loadChannels(eventsFile); err != nil {
+			return nil, err
+		}
+	}
+	return &loadedDataset{channels, events, sampleRate}, nil
+}
+
+func (d *loadedDataset) Channels() []Channel {
+	return d.channels
+}
+
+func (d *loadedDataset) Events() []Channel {
+	return d.events
+}
+
+func (d *loadedDataset) SampleRate() float64 {
+	return d.sampleRate
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EDF+ stores annotations in a signal with this label, which isn't EEG so is skipped.
+const edfAnnotationsLabel = "EDF Annotations"
+
+// edfSignal is the header for one signal in an EDF file.
+type edfSignal struct {
+	label            string
+	physicalMin      float64
+	physicalMax      float64
+	digitalMin       float64
+	digitalMax       float64
+	samplesPerRecord int
+}
+
+// LoadEDF loads the channels of an EDF or EDF+ file, along with their sample rate in Hz.
+// Digital values are scaled to their physical value (e.g. µV) using each signal's
+// physical and digital min/max, then rounded to whole numbers. EDF+ annotation signals are
+// skipped, and all other signals must have the same sample rate.
+func LoadEDF(filename string) ([]Channel, float64, error) {
+	fmt.Printf(" > Loading channels from %s\n", filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	in := bufio.NewReader(file)
+
+	header := make([]byte, 256)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, 0, fmt.Errorf("%s: reading header: %v", filename, err)
+	}
+	recordCount, err := edfInt(header[236:244])
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: number of data records: %v", filename, err)
+	}
+	recordDuration, err := edfFloat(header[244:252])
+	if err != nil || recordDuration <= 0 {
+		return nil, 0, fmt.Errorf("%s: invalid data record duration %q", filename, header[244:252])
+	}
+	signalCount, err := edfInt(header[252:256])
+	if err != nil || signalCount < 1 {
+		return nil, 0, fmt.Errorf("%s: invalid number of signals %q", filename, header[252:256])
+	}
+
+	signals, err := readEDFSignals(in, signalCount)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	channels := []Channel{}
+	sampleRate := 0.0
+	for _, signal := range signals {
+		if signal.label == edfAnnotationsLabel {
+			continue
+		}
+		rate := float64(signal.samplesPerRecord) / recordDuration
+		if len(channels) > 0 && rate != sampleRate {
+			return nil, 0, fmt.Errorf("%s: signal %s is sampled at %v Hz, but %s at %v Hz",
+				filename, signal.label, rate, channels[0].Id, sampleRate)
+		}
+		sampleRate = rate
+		channels = append(channels, Channel{signal.label, []int{}})
+	}
+	if len(channels) == 0 {
+		return nil, 0, fmt.Errorf("%s has no EEG signals", filename)
+	}
+
+	// Each data record has every signal's samples for that record in turn, as 16 bit
+	// little-endian integers. The record count may be -1 if it wasn't known when writing.
+	buf := []byte{}
+	for record := 0; recordCount < 0 || record < recordCount; record++ {
+		at := 0
+		for s, signal := range signals {
+			size := 2 * signal.samplesPerRecord
+			if cap(buf) < size {
+				buf = make([]byte, size)
+			}
+			buf = buf[:size]
+			if _, err := io.ReadFull(in, buf); err != nil {
+				if err == io.EOF && recordCount < 0 && s == 0 {
+					return channels, sampleRate, nil
+				}
+				return nil, 0, fmt.Errorf("%s: data record %d: %v", filename, record, err)
+			}
+			if signal.label == edfAnnotationsLabel {
+				continue
+			}
+
+			scale := (signal.physicalMax - signal.physicalMin) / (signal.digitalMax - signal.digitalMin)
+			for i := 0; i < signal.samplesPerRecord; i++ {
+				digital := float64(int16(binary.LittleEndian.Uint16(buf[2*i:])))
+				physical := (digital-signal.digitalMin)*scale + signal.physicalMin
+				channels[at].Samples = append(channels[at].Samples, int(math.Round(physical)))
+			}
+			at++
+		}
+	}
+	fmt.Printf("%d channels loaded, with %d samples\n", len(channels), len(channels[0].Samples))
+	return channels, sampleRate, nil
+}
+
+// readEDFSignals reads the per-signal part of the header, where each field is stored for
+// every signal before the next field.
+func readEDFSignals(in io.Reader, signalCount int) ([]edfSignal, error) {
+	// Widths of label, transducer, physical dimension, physical min, physical max,
+	// digital min, digital max, prefiltering, samples per record, and reserved.
+	widths := []int{16, 80, 8, 8, 8, 8, 8, 80, 8, 32}
+	fields := make([][]string, len(widths))
+	for f, width := range widths {
+		buf := make([]byte, width*signalCount)
+		if _, err := io.ReadFull(in, buf); err != nil {
+			return nil, fmt.Errorf("reading signal headers: %v", err)
+		}
+		fields[f] = make([]string, signalCount)
+		for s := range fields[f] {
+			fields[f][s] = strings.TrimSpace(string(buf[s*width : (s+1)*width]))
+		}
+	}
+
+	signals := make([]edfSignal, signalCount)
+	for s := range signals {
+		signal := &signals[s]
+		signal.label = fields[0][s]
+		if signal.label == edfAnnotationsLabel {
+			// Annotations have no scaling, only the size matters.
+			samples, err := strconv.Atoi(fields[8][s])
+			if err != nil {
+				return nil, fmt.Errorf("signal %s samples per record: %v", signal.label, err)
+			}
+			signal.samplesPerRecord = samples
+			continue
+		}
+
+		values := []*float64{&signal.physicalMin, &signal.physicalMax, &signal.digitalMin, &signal.digitalMax}
+		for v, value := range values {
+			parsed, err := strconv.ParseFloat(fields[3+v][s], 64)
+			if err != nil {
+				return nil, fmt.Errorf("signal %s: %v", signal.label, err)
+			}
+			*value = parsed
+		}
+		if signal.digitalMax == signal.digitalMin {
+			return nil, fmt.Errorf("signal %s has the same digital min and max", signal.label)
+		}
+		samples, err := strconv.Atoi(fields[8][s])
+		if err != nil || samples < 1 {
+			return nil, fmt.Errorf("signal %s has invalid samples per record %q", signal.label, fields[8][s])
+		}
+		signal.samplesPerRecord = samples
+	}
+	return signals, nil
+}
+
+// edfInt parses a space padded ASCII integer header field.
+func edfInt(field []byte) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(string(field)))
+}
+
+// edfFloat parses a space padded ASCII decimal header field.
+func edfFloat(field []byte) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(string(field)), 64)
+}
+
+func init() {
+	RegisterDatasetFormat(".edf", func(dataFile string, eventsFile string) (Dataset, error) {
+		channels, sampleRate, err := LoadEDF(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		var events []Channel
+		if eventsFile != "" {
+			if events, err = loadChannels(eventsFile); err != nil {
+				return nil, err
+			}
+		}
+		return &loadedDataset{channels, events, sampleRate}, nil
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// edfField pads a header value with spaces to its field width.
+func edfField(value string, width int) string {
+	return fmt.Sprintf("%-*s", width, value)
+}
+
+func TestLoadEDF(t *testing.T) {
+	// One EEG signal scaled by 0.1 from digital to physical, and an annotations signal,
+	// with two records of one second each.
+	header := edfField("0", 8) + edfField("patient", 80) + edfField("recording", 80) +
+		edfField("01.01.20", 8) + edfField("00.00.00", 8) + edfField("768", 8) + edfField("EDF+C", 44) +
+		edfField("2", 8) + edfField("1", 8) + edfField("2", 4)
+	signals := [][2]string{
+		{"Fp1", edfAnnotationsLabel},
+		{"", ""},
+		{"uV", ""},
+		{"-100", "-1"},
+		{"100", "1"},
+		{"-1000", "-32768"},
+		{"1000", "32767"},
+		{"", ""},
+		{"4", "2"},
+		{"", ""},
+	}
+	for f, width := range []int{16, 80, 8, 8, 8, 8, 8, 80, 8, 32} {
+		header += edfField(signals[f][0], width) + edfField(signals[f][1], width)
+	}
+
+	data := []byte(header)
+	for _, record := range [][]int16{{0, 100, -500, 1000, 0, 0}, {-1000, 5, 6, -4, 0, 0}} {
+		for _, v := range record {
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		}
+	}
+	filename := filepath.Join(t.TempDir(), "test.edf")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	channels, sampleRate, err := LoadEDF(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sampleRate != 4 {
+		t.Errorf("Expected a sample rate of 4 Hz, got %v", sampleRate)
+	}
+	if len(channels) != 1 || channels[0].Id != "Fp1" {
+		t.Fatalf("Expected only the Fp1 channel, got %v", channels)
+	}
+	expected := []int{0, 10, -50, 100, -100, 1, 1, 0}
+	if fmt.Sprint(channels[0].Samples) != fmt.Sprint(expected) {
+		t.Errorf("Expected samples %v, got %v", expected, channels[0].Samples)
+	}
+}
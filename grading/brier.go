@@ -0,0 +1,21 @@
+package grading
+
+// BrierScore is the mean squared difference between the predicted probabilities and the
+// actual [0, 1] events: 0 is perfect, and always predicting 0.5 scores 0.25. Unlike ROC AUC,
+// it depends on the probabilities themselves rather than just their order, so it shows
+// whether predictions are calibrated as well as whether they separate the classes.
+func BrierScore(actual []int, predictions []float64) float64 {
+	if len(actual) != len(predictions) {
+		panic("BrierScore requires actual and predictions to be the same size")
+	}
+	if len(actual) == 0 {
+		panic("BrierScore requires at least one prediction")
+	}
+
+	total := 0.0
+	for i, p := range predictions {
+		delta := p - float64(actual[i])
+		total += delta * delta
+	}
+	return total / float64(len(predictions))
+}
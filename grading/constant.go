@@ -0,0 +1,29 @@
+package grading
+
+import (
+	"log"
+
+	"github.com/padster/eego/util"
+)
+
+// WarnOnConstant controls whether the scoring functions log a warning when given
+// constant predictions, which carry no signal (e.g. a forest that is only a root).
+var WarnOnConstant = false
+
+// IsConstant returns whether every prediction is the same value.
+func IsConstant(predictions []float64) bool {
+	for _, p := range predictions {
+		if !util.Fpeq(p, predictions[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// warnIfConstant logs a warning about constant predictions, if enabled.
+func warnIfConstant(scorer string, predictions []float64) {
+	if WarnOnConstant && IsConstant(predictions) {
+		log.Printf("Warning: %s given %d constant predictions, the score will not be meaningful\n",
+			scorer, len(predictions))
+	}
+}
@@ -0,0 +1,42 @@
+package grading
+
+import (
+	"sort"
+)
+
+// GroupKFold assigns whole series to k folds, so that no series is split between training
+// and testing data. Adjacent EEG frames are highly correlated, so splitting a series would
+// leak information and give optimistic scores.
+//
+// Returns, for each fold, the indexes of the series held out for that fold. Series are
+// assigned largest first to whichever fold is smallest, so the folds are balanced by length.
+func GroupKFold(seriesLengths []int, k int) [][]int {
+	if k < 2 || k > len(seriesLengths) {
+		panic("GroupKFold requires 2 <= k <= number of series")
+	}
+
+	bySize := make([]int, len(seriesLengths), len(seriesLengths))
+	for i := range bySize {
+		bySize[i] = i
+	}
+	sort.SliceStable(bySize, func(i, j int) bool {
+		return seriesLengths[bySize[i]] > seriesLengths[bySize[j]]
+	})
+
+	folds, foldLengths := make([][]int, k, k), make([]int, k, k)
+	for _, series := range bySize {
+		smallest := 0
+		for fold := 1; fold < k; fold++ {
+			if foldLengths[fold] < foldLengths[smallest] {
+				smallest = fold
+			}
+		}
+		folds[smallest] = append(folds[smallest], series)
+		foldLengths[smallest] += seriesLengths[series]
+	}
+
+	for _, fold := range folds {
+		sort.Ints(fold)
+	}
+	return folds
+}
@@ -1,26 +1,120 @@
 package grading
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"sort"
 
 	"github.com/padster/eego/util"
 )
 
+// ErrDegenerateCurve is returned when there are not enough points on a curve to find its area,
+// e.g. when every prediction has the same value.
+var ErrDegenerateCurve = errors.New("curve requires two equal length arrays of size >= 2")
+
+// ErrSingleClass is returned when scoring events which are either all false or all true.
+var ErrSingleClass = errors.New("can't score: actual data is either all false or all true")
+
 // RocAuc returns the area under the Receiver operating characteristic (ROC) curve
 // See https://en.wikipedia.org/wiki/Receiver_operating_characteristic
-func RocAucScore(actual []int, predictions []float64) float64 {
-	// TODO: verify that actual contains both 0s and 1s, and nothing else, and both are same size.
+func RocAucScore(actual []int, predictions []float64) (float64, error) {
+	if err := validateBinary(actual, predictions); err != nil {
+		return 0, err
+	}
+	warnIfConstant("RocAucScore", predictions)
 	fps, tps, _ := rocCurve(actual, predictions)
 	return auc(fps, tps, true /* reorder */)
 }
 
+// RocCurve returns the points of the ROC curve that RocAucScore integrates: the false and
+// true positive rates when classifying predictions >= each threshold as 1. The points are
+// ordered by increasing threshold, so run from (1, 1) down to (0, 0), where the last
+// threshold is above every prediction.
+func RocCurve(actual []int, predictions []float64) (fpr, tpr, thresholds []float64, err error) {
+	if err := validateBinary(actual, predictions); err != nil {
+		return nil, nil, nil, err
+	}
+	fpr, tpr, thresholds = rocCurve(actual, predictions)
+	return fpr, tpr, thresholds, nil
+}
+
+// WeightedRocAucScore is RocAucScore where each prediction counts towards the true and false
+// positive rates with its weight, rather than 1, so that some periods can matter more.
+// Uniform weights give the same score as RocAucScore.
+func WeightedRocAucScore(actual []int, predictions []float64, weights []float64) (float64, error) {
+	if err := validateBinary(actual, predictions); err != nil {
+		return 0, err
+	}
+	if len(weights) != len(actual) {
+		return 0, fmt.Errorf("actual has %d values, but weights has %d", len(actual), len(weights))
+	}
+	classWeights := [2]float64{}
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) {
+			return 0, fmt.Errorf("weights[%d] is %f, expected a non-negative weight", i, w)
+		}
+		classWeights[actual[i]] += w
+	}
+	if classWeights[0] == 0 || classWeights[1] == 0 {
+		return 0, ErrSingleClass
+	}
+	warnIfConstant("WeightedRocAucScore", predictions)
+	fps, tps, _ := weightedRocCurve(actual, predictions, weights)
+	return auc(fps, tps, true /* reorder */)
+}
+
+// validateBinary checks that actual and predictions are the same size, and that actual
+// contains both 0s and 1s, and nothing else.
+func validateBinary(actual []int, predictions []float64) error {
+	if len(actual) != len(predictions) {
+		return fmt.Errorf("actual has %d values, but predictions has %d", len(actual), len(predictions))
+	}
+	seen := [2]bool{false, false}
+	for i, v := range actual {
+		if v != 0 && v != 1 {
+			return fmt.Errorf("actual[%d] is %d, expected only 0 or 1", i, v)
+		}
+		seen[v] = true
+	}
+	if !seen[0] || !seen[1] {
+		return ErrSingleClass
+	}
+	return nil
+}
+
+// MacroRocAuc scores each event channel separately with RocAucScore, and returns those
+// scores as well as their unweighted mean, matching how the Grasp-and-Lift competition is scored.
+func MacroRocAuc(actuals [][]int, predictions [][]float64) (perChannel []float64, macro float64, err error) {
+	if len(actuals) != len(predictions) {
+		return nil, 0, fmt.Errorf("%d actual channels, but %d prediction channels", len(actuals), len(predictions))
+	}
+	if len(actuals) == 0 {
+		return nil, 0, errors.New("no channels to score")
+	}
+
+	perChannel = make([]float64, len(actuals), len(actuals))
+	for i := range actuals {
+		if perChannel[i], err = RocAucScore(actuals[i], predictions[i]); err != nil {
+			return nil, 0, fmt.Errorf("channel %d: %w", i, err)
+		}
+		macro += perChannel[i]
+	}
+	return perChannel, macro / float64(len(actuals)), nil
+}
+
 // rocCurve takes an array of [0, 1] events, plus predicted probabilities, and returns
 // (fps, tps, thresholds) where:
 // thresholds[i] = the different guess thresholds possible
 // fps = false positive rate at each threshold
 // tps = true positive rate at each threshold
 func rocCurve(actual []int, predictions []float64) ([]float64, []float64, []float64) {
-	fps, tps, thresh := binaryClfCurve(actual, predictions)
+	return weightedRocCurve(actual, predictions, nil)
+}
+
+// weightedRocCurve is rocCurve where each prediction counts with its weight, rather than 1.
+func weightedRocCurve(actual []int, predictions []float64, weights []float64) ([]float64, []float64, []float64) {
+	fps, tps, thresh := weightedClfCurve(actual, predictions, weights)
 	n := len(fps)
 
 	if n == 0 {
@@ -39,10 +133,10 @@ func rocCurve(actual []int, predictions []float64) ([]float64, []float64, []floa
 	}
 
 	normFps, normTps := make([]float64, n, n), make([]float64, n, n)
-	scaleFps, scaleTps := 1.0/float64(fps[0]), 1.0/float64(tps[0])
+	scaleFps, scaleTps := 1.0/fps[0], 1.0/tps[0]
 	for i := 0; i < n; i++ {
-		normFps[i] = float64(fps[i]) * scaleFps
-		normTps[i] = float64(tps[i]) * scaleTps
+		normFps[i] = fps[i] * scaleFps
+		normTps[i] = tps[i] * scaleTps
 	}
 	return normFps, normTps, thresh
 }
@@ -50,33 +144,55 @@ func rocCurve(actual []int, predictions []float64) ([]float64, []float64, []floa
 // binaryClfCurve identifies the important classification thresholds, and
 // calculates true and false positive counts for each.
 func binaryClfCurve(actual []int, predictions []float64) ([]int, []int, []float64) {
+	weightedFps, weightedTps, thresh := weightedClfCurve(actual, predictions, nil)
+	fps, tps := make([]int, len(thresh), len(thresh)), make([]int, len(thresh), len(thresh))
+	for i := range thresh {
+		fps[i], tps[i] = int(weightedFps[i]), int(weightedTps[i])
+	}
+	return fps, tps, thresh
+}
+
+// weightedClfCurve is binaryClfCurve where each prediction counts with its weight,
+// rather than 1. nil weights count every prediction as 1.
+func weightedClfCurve(actual []int, predictions []float64, weights []float64) ([]float64, []float64, []float64) {
 	n := len(actual)
-	fps, tps, thresh := make([]int, 0, n), make([]int, 0, n), make([]float64, 0, n)
+	fps, tps, thresh := make([]float64, 0, n), make([]float64, 0, n), make([]float64, 0, n)
 
-	toSort := util.DualSortFI{predictions, actual}
-	sort.Sort(toSort)
-	actual, predictions = toSort.V2, toSort.V1
+	// Sort exactly, so the order doesn't depend on fuzzy tie-breaks.
+	// Sorted copies are used so the caller's slices are left untouched.
+	order := util.Argsort(predictions)
+	sortedActual, sortedPredictions := make([]int, n, n), make([]float64, n, n)
+	for i, at := range order {
+		sortedActual[i], sortedPredictions[i] = actual[at], predictions[at]
+	}
+	actual, predictions = sortedActual, sortedPredictions
 
-	truePos := 0
-	for _, v := range actual {
-		if v == 1 {
-			truePos++
+	// Weight of the positives and negatives at or after each position, summed from the end
+	// so that no rounding error is left over from subtracting.
+	truePos, falsePos := make([]float64, n+1, n+1), make([]float64, n+1, n+1)
+	for i := n - 1; i >= 0; i-- {
+		weight := 1.0
+		if weights != nil {
+			weight = weights[order[i]]
+		}
+		truePos[i], falsePos[i] = truePos[i+1], falsePos[i+1]
+		if actual[i] == 1 {
+			truePos[i] += weight
+		} else {
+			falsePos[i] += weight
 		}
 	}
-	falsePos := n - truePos
 
-	for i := 0; i < n; i++ {
-		shouldGuess := i == 0 || !util.Fpeq(predictions[i], predictions[i-1])
-		if shouldGuess {
-			fps = append(fps, falsePos)
-			tps = append(tps, truePos)
-			thresh = append(thresh, predictions[i])
-		}
+	for i := 0; i < n; {
+		fps = append(fps, falsePos[i])
+		tps = append(tps, truePos[i])
+		thresh = append(thresh, predictions[i])
 
-		if actual[i] == 0 {
-			falsePos--
-		} else {
-			truePos--
+		// Skip all the predictions tied with this one, as they share a single threshold.
+		// Only identical values are ties, so close but different predictions stay separate.
+		groupStart := i
+		for i < n && predictions[i] == predictions[groupStart] {
+			i++
 		}
 	}
 
@@ -84,16 +200,16 @@ func binaryClfCurve(actual []int, predictions []float64) ([]int, []int, []float6
 }
 
 // Calculate area under the given curve using trapezoidal rules
-func auc(xs []float64, ys []float64, reorder bool) float64 {
+func auc(xs []float64, ys []float64, reorder bool) (float64, error) {
 	if len(xs) < 2 || len(xs) != len(ys) {
-		panic("auc() requires two equal length arrays of size >= 2")
+		return 0, ErrDegenerateCurve
 	}
 
-	toSort := util.DualSortFF{xs, ys}
+	toSort := util.DualSortFF{V1: xs, V2: ys, Exact: true}
 	if reorder {
 		sort.Sort(toSort)
 	}
-	return trapz(toSort.V2, toSort.V1)
+	return trapz(toSort.V2, toSort.V1), nil
 }
 
 // Calculate the area using the trapezium rule
@@ -105,3 +221,43 @@ func trapz(ys []float64, xs []float64) float64 {
 	}
 	return ans * 0.5
 }
+
+// PrAucScore returns the area under the precision-recall curve, which is more informative
+// than ROC AUC when positive events are rare.
+// See https://en.wikipedia.org/wiki/Precision_and_recall
+func PrAucScore(actual []int, predictions []float64) (float64, error) {
+	if err := validateBinary(actual, predictions); err != nil {
+		return 0, err
+	}
+	warnIfConstant("PrAucScore", predictions)
+	precision, recall := prCurve(actual, predictions)
+	return auc(recall, precision, true /* reorder */)
+}
+
+// prCurve takes an array of [0, 1] events, plus predicted probabilities, and returns the
+// (precision, recall) at each threshold, plus the (1, 0) point for the highest threshold.
+// Like sklearn, the curve starts at the highest threshold with full recall, as lower ones
+// only add false positives, which would drag the area down at recall 1.
+func prCurve(actual []int, predictions []float64) ([]float64, []float64) {
+	fps, tps, _ := binaryClfCurve(actual, predictions)
+	n := len(tps)
+	if n == 0 || tps[0] == 0 {
+		panic("Can't score: actual data has no true events.")
+	}
+	first := 0
+	for first+1 < n && tps[first+1] == tps[0] {
+		first++
+	}
+
+	precision, recall := make([]float64, 0, n+1-first), make([]float64, 0, n+1-first)
+	for i := first; i < n; i++ {
+		if tps[i]+fps[i] == 0 {
+			continue
+		}
+		precision = append(precision, float64(tps[i])/float64(tps[i]+fps[i]))
+		recall = append(recall, float64(tps[i])/float64(tps[0]))
+	}
+	precision = append(precision, 1.0)
+	recall = append(recall, 0.0)
+	return precision, recall
+}
@@ -0,0 +1,84 @@
+package grading
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/padster/eego/util"
+)
+
+func TestRocAucScore(t *testing.T) {
+	cases := []struct {
+		actual      []int
+		predictions []float64
+		expected    float64
+	}{
+		{[]int{0, 0, 1, 1}, []float64{0.1, 0.4, 0.35, 0.8}, 0.75},
+		{[]int{0, 0, 0, 0, 1, 1, 1}, []float64{0.1, 0.6, 0.6, 0.23, 0.1, 0.23, 0.5}, 1.0 / 3.0},
+		{[]int{1, 0, 1, 0, 1, 1, 1, 1}, []float64{0.8, 0.5, 0.44, 0.1, 0.2, 0.9, 0.9, 0.5}, 0.7916666},
+		// Ties between positive and negative predictions count as half.
+		{[]int{0, 1, 1, 0, 1}, []float64{0.5, 0.5, 0.9, 0.1, 0.5}, 5.0 / 6.0},
+		{[]int{1, 0, 1, 0}, []float64{1.0, 1.0, 1.0, 0.0}, 0.75},
+		// Predictions closer than Fpeq's tolerance are still different thresholds.
+		{[]int{0, 1}, []float64{0.5, 0.500001}, 1.0},
+		{[]int{0, 1, 0, 1}, []float64{0.5, 0.500001, 0.1, 0.9}, 1.0},
+	}
+
+	for i, c := range cases {
+		score, err := RocAucScore(c.actual, c.predictions)
+		if err != nil {
+			t.Errorf("Case %d failed: %v", i, err)
+		} else if !util.Fpeq(score, c.expected) {
+			t.Errorf("Case %d: expected AUC %f, got %f", i, c.expected, score)
+		}
+	}
+}
+
+func TestWeightedRocAucScore(t *testing.T) {
+	actual := []int{1, 0, 1, 0, 1, 1, 1, 1}
+	predictions := []float64{0.8, 0.5, 0.44, 0.1, 0.2, 0.9, 0.9, 0.5}
+
+	uniform := []float64{1, 1, 1, 1, 1, 1, 1, 1}
+	expected, _ := RocAucScore(actual, predictions)
+	if score, err := WeightedRocAucScore(actual, predictions, uniform); err != nil || !util.Fpeq(score, expected) {
+		t.Errorf("Uniform weights: expected AUC %f, got %f (%v)", expected, score, err)
+	}
+
+	// Weighing a prediction by 2 is the same as including it twice.
+	doubled := []float64{2, 1, 1, 1, 1, 1, 1, 1}
+	expected, _ = RocAucScore(append([]int{1}, actual...), append([]float64{0.8}, predictions...))
+	if score, err := WeightedRocAucScore(actual, predictions, doubled); err != nil || !util.Fpeq(score, expected) {
+		t.Errorf("Doubled weight: expected AUC %f, got %f (%v)", expected, score, err)
+	}
+}
+
+func TestPrAucScore(t *testing.T) {
+	cases := []struct {
+		actual      []int
+		predictions []float64
+		expected    float64
+	}{
+		// A perfect ranking has precision 1 at every recall.
+		{[]int{0, 1, 1, 0}, []float64{0.1, 0.9, 0.8, 0.2}, 1.0},
+		// Points (r, p) = (1, 2/3), (0.5, 0.5), (0.5, 1), (0, 1), as sklearn's
+		// auc(recall, precision) gives.
+		{[]int{0, 0, 1, 1}, []float64{0.1, 0.4, 0.35, 0.8}, 0.7916666},
+	}
+
+	for i, c := range cases {
+		score, err := PrAucScore(c.actual, c.predictions)
+		if err != nil {
+			t.Errorf("Case %d failed: %v", i, err)
+		} else if !util.Fpeq(score, c.expected) {
+			t.Errorf("Case %d: expected PR AUC %f, got %f", i, c.expected, score)
+		}
+	}
+}
+
+func TestMacroRocAucSingleClass(t *testing.T) {
+	actuals := [][]int{{0, 1, 0, 1}, {0, 0, 0, 0}}
+	predictions := [][]float64{{0.1, 0.9, 0.2, 0.8}, {0.1, 0.9, 0.2, 0.8}}
+	if _, _, err := MacroRocAuc(actuals, predictions); !errors.Is(err, ErrSingleClass) {
+		t.Errorf("Expected ErrSingleClass for an all 0 channel, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package grading
+
+import (
+	"github.com/padster/eego/signal"
+)
+
+// SmoothPredictions replaces each prediction with the mean of the window predictions centered
+// on it, so that per-frame probabilities don't flip rapidly around long-lasting events.
+// The window shrinks near the edges, so the output is the same length as the input.
+func SmoothPredictions(predictions []float64, window int) []float64 {
+	return signal.MovingAverageFloat(predictions, window)
+}
@@ -0,0 +1,153 @@
+package grading
+
+// ConfusionMatrix holds the counts of each outcome when classifying at a single threshold.
+type ConfusionMatrix struct {
+	TP, FP, TN, FN int
+}
+
+// Confusion classifies predictions >= threshold as 1, and counts how they compare to
+// the actual [0, 1] events.
+func Confusion(actual []int, predictions []float64, threshold float64) ConfusionMatrix {
+	if len(actual) != len(predictions) {
+		panic("Confusion requires actual and predictions to be the same size")
+	}
+
+	cm := ConfusionMatrix{}
+	for i, p := range predictions {
+		predictTrue := p >= threshold
+		switch {
+		case predictTrue && actual[i] == 1:
+			cm.TP++
+		case predictTrue:
+			cm.FP++
+		case actual[i] == 1:
+			cm.FN++
+		default:
+			cm.TN++
+		}
+	}
+	return cm
+}
+
+// Accuracy is the fraction of all predictions which were correct, 0 if there are none.
+func (cm ConfusionMatrix) Accuracy() float64 {
+	return ratio(cm.TP+cm.TN, cm.TP+cm.FP+cm.TN+cm.FN)
+}
+
+// Precision is the fraction of predicted 1s which were correct, 0 if nothing was predicted as 1.
+func (cm ConfusionMatrix) Precision() float64 {
+	return ratio(cm.TP, cm.TP+cm.FP)
+}
+
+// Recall is the fraction of actual 1s which were predicted, 0 if nothing was actually 1.
+func (cm ConfusionMatrix) Recall() float64 {
+	return ratio(cm.TP, cm.TP+cm.FN)
+}
+
+// Specificity is the fraction of actual 0s which were predicted, 0 if nothing was actually 0.
+func (cm ConfusionMatrix) Specificity() float64 {
+	return ratio(cm.TN, cm.TN+cm.FP)
+}
+
+// F1 is the harmonic mean of precision and recall, 0 if both are 0.
+func (cm ConfusionMatrix) F1() float64 {
+	precision, recall := cm.Precision(), cm.Recall()
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// PrecisionRecallF1 classifies predictions >= threshold as 1, and compares them to the actual
+// [0, 1] events. Precision is 0 if nothing is predicted as 1, recall is 0 if nothing is actually 1,
+// and F1 is 0 if both precision and recall are 0.
+func PrecisionRecallF1(actual []int, predictions []float64, threshold float64) (precision, recall, f1 float64) {
+	cm := Confusion(actual, predictions, threshold)
+	return cm.Precision(), cm.Recall(), cm.F1()
+}
+
+// ratio divides two counts, returning 0 rather than NaN when the denominator is 0.
+func ratio(numerator int, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// Accuracy classifies predictions >= threshold as 1, and returns the fraction which match
+// the actual [0, 1] events, 0 if there are none.
+func Accuracy(actual []int, predictions []float64, threshold float64) float64 {
+	return Confusion(actual, predictions, threshold).Accuracy()
+}
+
+// BalancedAccuracy is the mean of the recall of each class, i.e. of recall and specificity,
+// so that rare events count as much as the common non-events. Classes which don't occur
+// in actual are left out of the mean.
+func BalancedAccuracy(actual []int, predictions []float64, threshold float64) float64 {
+	return Confusion(actual, predictions, threshold).BalancedAccuracy()
+}
+
+// BalancedAccuracy is the mean of recall and specificity, see the BalancedAccuracy function.
+func (cm ConfusionMatrix) BalancedAccuracy() float64 {
+	total, classes := 0.0, 0
+	if cm.TP+cm.FN > 0 {
+		total += cm.Recall()
+		classes++
+	}
+	if cm.TN+cm.FP > 0 {
+		total += cm.Specificity()
+		classes++
+	}
+	if classes == 0 {
+		return 0
+	}
+	return total / float64(classes)
+}
+
+// CohensKappa classifies predictions >= threshold as 1, and returns how much better their
+// agreement with the actual [0, 1] events is than chance, given how often each class is
+// predicted and occurs: 1 is perfect agreement, 0 no better than chance, and negative worse.
+// Unlike Accuracy, always predicting 0 for rare events scores 0 rather than close to 1.
+func CohensKappa(actual []int, predictions []float64, threshold float64) float64 {
+	return Confusion(actual, predictions, threshold).CohensKappa()
+}
+
+// CohensKappa is the agreement corrected for chance, see the CohensKappa function.
+// Returns 0 if chance agreement is already perfect, e.g. everything is one class.
+func (cm ConfusionMatrix) CohensKappa() float64 {
+	n := float64(cm.TP + cm.FP + cm.TN + cm.FN)
+	if n == 0 {
+		return 0
+	}
+	observed := cm.Accuracy()
+	chance := (float64(cm.TP+cm.FP)*float64(cm.TP+cm.FN) +
+		float64(cm.TN+cm.FN)*float64(cm.TN+cm.FP)) / (n * n)
+	if chance == 1 {
+		return 0
+	}
+	return (observed - chance) / (1 - chance)
+}
+
+// BestThreshold tries each distinct prediction value as the threshold (the same thresholds
+// as the ROC curve), and returns the one giving the highest metric, e.g.
+// ConfusionMatrix.F1, along with that score. Earlier (lower) thresholds win ties.
+func BestThreshold(actual []int, predictions []float64,
+	metric func(ConfusionMatrix) float64) (threshold, score float64) {
+	if len(actual) != len(predictions) {
+		panic("BestThreshold requires actual and predictions to be the same size")
+	}
+	if len(actual) == 0 {
+		panic("BestThreshold requires at least one prediction")
+	}
+
+	fps, tps, thresh := binaryClfCurve(actual, predictions)
+	// The lowest threshold classifies everything as 1, so has every positive and negative.
+	positives, negatives := tps[0], fps[0]
+	for i := range thresh {
+		cm := ConfusionMatrix{tps[i], fps[i], negatives - fps[i], positives - tps[i]}
+		if s := metric(cm); i == 0 || s > score {
+			threshold, score = thresh[i], s
+		}
+	}
+	return threshold, score
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/csv"
+	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
-	// "runtime"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"time"
 
@@ -18,11 +23,71 @@ type Channel struct {
 	Samples []int
 }
 
+// DataConfig describes where the EEG recordings are stored.
+type DataConfig struct {
+	// Directory holding the train/ and test/ subdirectories.
+	BaseDir string
+	// Filename patterns within those, formatted with the subject then series number.
+	DataPattern   string
+	EventsPattern string
+}
+
+// DefaultDataConfig is the layout of the grasp-and-lift dataset, relative to the working directory.
+var DefaultDataConfig = DataConfig{
+	"data",
+	"subj%d_series%d_data.csv",
+	"subj%d_series%d_events.csv",
+}
+
+// DataFile returns the path of the EEG data for a subject and series.
+func (dc DataConfig) DataFile(subject int, series int, test bool) string {
+	dir := "train"
+	if test {
+		dir = "test"
+	}
+	return filepath.Join(dc.BaseDir, dir, fmt.Sprintf(dc.DataPattern, subject, series))
+}
+
+// EventsFile returns the path of the training events for a subject and series.
+func (dc DataConfig) EventsFile(subject int, series int) string {
+	return filepath.Join(dc.BaseDir, "train", fmt.Sprintf(dc.EventsPattern, subject, series))
+}
+
+// FloatChannel is a Channel whose samples aren't whole numbers, e.g. after preprocessing.
+type FloatChannel struct {
+	Id      string
+	Samples []float64
+}
+
 func main() {
 	// runtime.GOMAXPROCS(2)
+	config := DefaultDataConfig
+	flag.StringVar(&config.BaseDir, "data", config.BaseDir, "Directory containing the train and test data.")
+	flag.StringVar(&config.DataPattern, "data_pattern", config.DataPattern, "Filename of EEG data, given subject and series numbers.")
+	flag.StringVar(&config.EventsPattern, "events_pattern", config.EventsPattern, "Filename of events, given subject and series numbers.")
+	resultsFile := flag.String("results", "", "If set, grade every -subjects and -series combination, writing CSV results to this file.")
+	subjectsFlag := flag.String("subjects", "1,2,3,4,5,6,7,8,9,10,11,12", "Comma separated subjects to grade with -results.")
+	seriesFlag := flag.String("series", "1,2,3,4,5,6,7,8", "Comma separated series to grade with -results.")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "How many subject and series combinations to grade at once with -results.")
+	flag.Parse()
+
+	if *resultsFile != "" {
+		if err := writeBatch(*resultsFile, config, parseInts(*subjectsFlag), parseInts(*seriesFlag), *concurrency); err != nil {
+			fmt.Printf("Batch failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	subject, series := 1, 1
-	eeg := loadData(subject, series, false)
-	events := loadEvents(subject, series)
+	eeg, err := loadData(config, subject, series, false)
+	if err != nil {
+		panic(err)
+	}
+	events, err := loadEvents(config, subject, series)
+	if err != nil {
+		panic(err)
+	}
  
  	// Renders the EEG data for one of the channels to screen:
  	s := util.NewScreen(1600, 400, 1)
@@ -35,41 +100,63 @@ func main() {
 	// verifyAuc()
 }
 
-func gradeSubjectSeries(subject int, trainSeries int, testSeries int) {
+// gradeSubjectSeries trains a forest for every pair of EEG and event channels in one series,
+// and scores it on another, both of which must have events. Write the results with WriteResults,
+// or print them with printResult. Nothing is printed here, as RunBatch calls this concurrently.
+func gradeSubjectSeries(config DataConfig, subject int, trainSeries int, testSeries int) ([]Result, error) {
 	// EEG_CHANNEL := "FC1"
 	// EVENT_CHANNEL := "FirstDigitTouch"
 
-	fmt.Printf("Loading training data...\n")
-	data := loadData(subject, trainSeries, false)
+	train, err := config.Open(subject, trainSeries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load training data: %v", err)
+	}
+	data, events := train.Channels(), train.Events()
 
-	fmt.Printf("Loading training events...\n")
-	events := loadEvents(1, 1)
+	test, err := config.Open(subject, testSeries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load test data: %v", err)
+	}
+	testData, testEvents := test.Channels(), test.Events()
 	
-	fmt.Printf("Training...\n")
+	results := []Result{}
 	for _, vd := range data {
 		for _, ve := range events {
 			f := trees.NewForest(150, 1, 1000)
 			f.Train(vd.Samples, ve.Samples)
-			dId, eId := vd.Id, ve.Id
-			if len(dId) > 4 {
-				dId = dId[:4]
+			auc, err := grading.RocAucScore(channelSamples(testEvents, ve.Id),
+				f.Classify(channelSamples(testData, vd.Id)))
+			if err != nil {
+				auc = math.NaN()
 			}
-			for len(dId) < 4 {
-				dId = dId + "_"
-			}
-			if len(eId) > 7 {
-				eId = eId[:7]
-			}
-			for len(eId) < 7 {
-				eId = eId + "_"
-			}
-
-			fmt.Printf("%s\t%s\tV = %d\t~E = %f\n", 
-				dId, eId, f.DecisionNodes(), f.AverageErrors())
+			results = append(results, Result{
+				subject, trainSeries, testSeries,
+				vd.Id, ve.Id, f.DecisionNodes(), f.AverageErrors(), auc,
+			})
 		}
 	}
+	return results, nil
+}
 
-	fmt.Printf("Trained!\n")
+// printResult prints the size and training errors of the forest for one result, with the
+// channel names padded or truncated to line up.
+func printResult(r Result) {
+	dId, eId := r.DataChannel, r.EventChannel
+	if len(dId) > 4 {
+		dId = dId[:4]
+	}
+	for len(dId) < 4 {
+		dId = dId + "_"
+	}
+	if len(eId) > 7 {
+		eId = eId[:7]
+	}
+	for len(eId) < 7 {
+		eId = eId + "_"
+	}
+
+	fmt.Printf("%s\t%s\tV = %d\t~E = %f\n", 
+		dId, eId, r.Nodes, r.AvgError)
 }
 
 func channelSamples(channels []Channel, id string) []int {
@@ -84,82 +171,177 @@ func channelSamples(channels []Channel, id string) []int {
 // verifies the AUC grades for some test cases.
 func verifyAuc() {
 	// TODO(padster): migrate to test suite
-	fmt.Printf("3/4 == %f\n", grading.RocAucScore(
+	printAuc("3/4",
 		[]int{0, 0, 1, 1},
 		[]float64{0.1, 0.4, 0.35, 0.8},
-	))
-	fmt.Printf("1/3 == %f\n", grading.RocAucScore(
+	)
+	printAuc("1/3",
 		[]int{0, 0, 0, 0, 1, 1, 1},
 		[]float64{0.1, 0.6, 0.6, 0.23, 0.1, 0.23, 0.5},
-	))
-	fmt.Printf("0.7916.. == %f\n", grading.RocAucScore(
+	)
+	printAuc("0.7916..",
 		[]int{1, 0, 1, 0, 1, 1, 1, 1},
 		[]float64{0.8, 0.5, 0.44, 0.1, 0.2, 0.9, 0.9, 0.5},
-	))
+	)
 }
 
-// loadData Loads EEG channel data for a given subject and series.
-func loadData(subject int, series int, test bool) []Channel {
-	var filename string
-	if test {
-		filename = fmt.Sprintf("data/test/subj%d_series%d_data.csv", subject, series)
+// printAuc prints the AUC score next to what it is expected to be.
+func printAuc(expected string, actual []int, predictions []float64) {
+	if score, err := grading.RocAucScore(actual, predictions); err == nil {
+		fmt.Printf("%s == %f\n", expected, score)
 	} else {
-		filename = fmt.Sprintf("data/train/subj%d_series%d_data.csv", subject, series)
+		fmt.Printf("%s, but failed: %v\n", expected, err)
 	}
-	return loadChannels(filename)
+}
+
+// loadData Loads EEG channel data for a given subject and series.
+func loadData(config DataConfig, subject int, series int, test bool) ([]Channel, error) {
+	return loadChannels(config.DataFile(subject, series, test))
 }
 
 // loadEvents loads event flags for a given subject and series.
-func loadEvents(subject int, series int) []Channel {
-	filename := fmt.Sprintf("data/train/subj%d_series%d_events.csv", subject, series)
-	return loadChannels(filename)
+func loadEvents(config DataConfig, subject int, series int) ([]Channel, error) {
+	return loadChannels(config.EventsFile(subject, series))
 }
 
 // loadChannels loads the CSV into column-major array of channels.
-func loadChannels(filename string) []Channel {
+func loadChannels(filename string) ([]Channel, error) {
+	return loadChannelsStream(filename, nil)
+}
+
+// How many rows are read between calls to the progress callback when streaming.
+const progressRows = 10000
+
+// loadChannelsStream loads the CSV into column-major array of channels, one row at a time
+// rather than reading the whole file into memory first. If onProgress is not nil, it is
+// called periodically with the number of rows read so far, and once more at the end.
+func loadChannelsStream(filename string, onProgress func(rowsRead int)) ([]Channel, error) {
 	fmt.Printf(" > Loading channels from %s\n", filename)
-	if file, err := os.Open(filename); err == nil {
-		defer file.Close()
-
-		r := csv.NewReader(file)
-		r.FieldsPerRecord = -1
-
-		if data, err := r.ReadAll(); err == nil {
-			channels := make([]Channel, len(data[0])-1, len(data[0])-1)
-			for i, cid := range data[0] {
-				if i != 0 {
-					channels[i-1] = Channel{
-						cid,
-						make([]int, len(data)-1),
-					}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s is empty, expected a header row", filename)
+	} else if err != nil {
+		return nil, err
+	}
+
+	channels := make([]Channel, len(header)-1, len(header)-1)
+	for i, cid := range header {
+		if i != 0 {
+			channels[i-1] = Channel{cid, []int{}}
+		}
+	}
+
+	rowsRead := 0
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		rowsRead++
+
+		if len(row) != len(header) {
+			return nil, fmt.Errorf("%s row %d has %d columns, expected %d", filename, rowsRead, len(row), len(header))
+		}
+		for j, s := range row {
+			if j != 0 {
+				value, err := strconv.Atoi(s)
+				if err != nil {
+					return nil, fmt.Errorf("%s row %d, column %d: %v", filename, rowsRead, j, err)
 				}
+				channels[j-1].Samples = append(channels[j-1].Samples, value)
 			}
-			for i, row := range data {
-				if i != 0 {
-					for j, s := range row {
-						if j != 0 {
-							channels[j-1].Samples[i-1], _ = strconv.Atoi(s)
-						}
-					}
+		}
+		if onProgress != nil && rowsRead%progressRows == 0 {
+			onProgress(rowsRead)
+		}
+	}
+	if onProgress != nil {
+		onProgress(rowsRead)
+	}
+
+	if len(channels) > 0 {
+		fmt.Printf("%d channels loaded, with %d samples\n", len(channels), len(channels[0].Samples))
+	}
+	return channels, nil
+}
+
+// loadChannelsFloat loads the CSV into column-major array of channels, keeping decimal values.
+// Like loadChannelsStream, rows are read one at a time, and must have as many columns as the header.
+func loadChannelsFloat(filename string) ([]FloatChannel, error) {
+	fmt.Printf(" > Loading channels from %s\n", filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s is empty, expected a header row", filename)
+	} else if err != nil {
+		return nil, err
+	}
+
+	channels := make([]FloatChannel, len(header)-1, len(header)-1)
+	for i, cid := range header {
+		if i != 0 {
+			channels[i-1] = FloatChannel{cid, []float64{}}
+		}
+	}
+
+	rowsRead := 0
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		rowsRead++
+
+		if len(row) != len(header) {
+			return nil, fmt.Errorf("%s row %d has %d columns, expected %d", filename, rowsRead, len(row), len(header))
+		}
+		for j, s := range row {
+			if j != 0 {
+				value, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s row %d, column %d: %v", filename, rowsRead, j, err)
 				}
+				channels[j-1].Samples = append(channels[j-1].Samples, value)
 			}
-			fmt.Printf("%d channels loaded, with %d samples\n", len(channels), len(channels[0].Samples))
-			return channels
-		} else {
-			panic(err)
 		}
-	} else {
-		panic(err)
 	}
+
+	if len(channels) > 0 {
+		fmt.Printf("%d channels loaded, with %d samples\n", len(channels), len(channels[0].Samples))
+	}
+	return channels, nil
 }
 
 // asUiChannel converts an array of values into a realtime(ish) channel of samples.
 func asUiChannel(samples []int) <-chan float64 {
-	min, max := minMax(samples)
+	// NOTE(padster): some data has some really big extremes, so clip those to keep the same scale.
+	min, max := minMaxClipped(samples, 1.0, 99.0)
 	c := make(chan float64)
 	go func() {
-		for _, s := range samples {
-			scaled := 2.00*float64(s-min)/float64(max-min) - 1.0
+		for _, scaled := range scaleClipped(samples, min, max, -1.0, 1.0) {
 			c <- scaled
 			time.Sleep(2 * time.Millisecond)
 		}
@@ -167,42 +349,83 @@ func asUiChannel(samples []int) <-chan float64 {
 	return c
 }
 
-// minMax returns the highest and lowest values in an array
+// minMax returns the lowest and highest values in an array
 func minMax(values []int) (int, int) {
-	/*
-		min, max := values[0], values[0]
-		for _, v := range values {
-			if v < min {
-				min = v
-			} else if v > max {
-				max = v
-			}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		} else if v > max {
+			max = v
+		}
+	}
+	return min, max
+}
+
+// minMaxClipped returns the values at the given low and high percentiles (0 - 100) of an
+// array, so that a few extreme outliers don't dominate the range. Empty arrays give 0, 0.
+func minMaxClipped(values []int, lowPct float64, highPct float64) (int, int) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	percentile := func(pct float64) int {
+		at := int(pct/100.0*float64(len(sorted)-1) + 0.5)
+		if at < 0 {
+			at = 0
+		} else if at >= len(sorted) {
+			at = len(sorted) - 1
 		}
-		return min, max
-	*/
-	// NOTE(padster): some data has some really big extremes. This normalizes them to the same scale.
-	return -1200, 3000
+		return sorted[at]
+	}
+	return percentile(lowPct), percentile(highPct)
+}
+
+// Colour for each event channel, as a bitmask of red (4), green (2) and blue (1),
+// so that the colours of simultaneous events can be combined by OR-ing them.
+// With more than 6 event channels, the colours repeat.
+var eventColours = []int{
+	4, // red
+	6, // yellow
+	2, // green
+	3, // cyan
+	1, // blue
+	5, // magenta
+}
+
+// Event to display for each combined colour bitmask.
+var rgbEvents = [8]util.Event{
+	{0.0, 0.0, 0.0},
+	{0.0, 0.0, 1.0},
+	{0.0, 1.0, 0.0},
+	{0.0, 1.0, 1.0},
+	{1.0, 0.0, 0.0},
+	{1.0, 0.0, 1.0},
+	{1.0, 1.0, 0.0},
+	{1.0, 1.0, 1.0},
 }
 
 // asEventChannel converts an array of 0/1 events to an event at that time.
+// When several events are active at once, their colours are OR-ed together.
+// Any number of event channels is supported, if there are none the result is empty.
 func asEventChannel(message string, events []Channel) <-chan interface{} {
 	c := make(chan interface{})
 	go func() {
+		if len(events) == 0 {
+			close(c)
+			return
+		}
 		for i := 0; i < len(events[0].Samples); i++ {
-			switch {
-			case events[0].Samples[i] == 1:
-				c <- util.Event{1.0, 0.0, 0.0}
-			case events[1].Samples[i] == 1:
-				c <- util.Event{1.0, 1.0, 0.0}
-			case events[2].Samples[i] == 1:
-				c <- util.Event{0.0, 1.0, 0.0}
-			case events[3].Samples[i] == 1:
-				c <- util.Event{0.0, 1.0, 1.0}
-			case events[4].Samples[i] == 1:
-				c <- util.Event{0.0, 0.0, 1.0}
-			case events[5].Samples[i] == 1:
-				c <- util.Event{1.0, 0.0, 1.0}
-			default:
+			colour := 0
+			for e, ch := range events {
+				if i < len(ch.Samples) && ch.Samples[i] == 1 {
+					colour |= eventColours[e%len(eventColours)]
+				}
+			}
+			if colour != 0 {
+				c <- rgbEvents[colour]
+			} else {
 				c <- nil
 			}
 			time.Sleep(2 * time.Millisecond)
@@ -5,6 +5,8 @@ package ml
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 )
 
 // Polynomial coefficients, state[0] + state[1] * x
@@ -13,43 +15,192 @@ type GDLRState [2]float64
 type GradDescLinReg struct {
 	state GDLRState
 	alpha float64
+	// L2 regularization strength, applied to all but the bias term.
+	lambda float64
+	// Number of random samples used for each update, 0 to use all of them.
+	batchSize int
+	rng *rand.Rand
+	// Fraction of the previous update carried over into the next, 0 for plain descent.
+	momentum float64
+
+	// Train gives up after this many iterations without converging.
+	MaxIterations int
+	// Train has converged once the squared distance between updates is below this.
+	Tolerance float64
+
+	// If set, called with the state at the start of each iteration of Train.
+	OnIteration func(iter int, state GDLRState)
+}
+
+// ConvergenceError is returned when training runs out of iterations before converging.
+type ConvergenceError struct {
+	Iterations int
+	// Squared distance between the last two states.
+	UpdateDistSq float64
+}
+
+func (e *ConvergenceError) Error() string {
+	return fmt.Sprintf("no convergence after %d iterations, last update distance^2 = %g",
+		e.Iterations, e.UpdateDistSq)
 }
 
 // State for performing linear regression by gradient descent.
 func NewGradDescLinReg(alpha float64) *GradDescLinReg {
+	return NewRidgeGradDescLinReg(alpha, 0.0)
+}
+
+// State for performing ridge regression (linear regression with L2 regularization)
+// by gradient descent. A lambda of 0 is the same as plain linear regression.
+func NewRidgeGradDescLinReg(alpha float64, lambda float64) *GradDescLinReg {
 	return &GradDescLinReg{
 		[...]float64{0., 0.},
 		alpha,
+		lambda,
+		0, // batchSize
+		rand.New(rand.NewSource(1)),
+		0.0, // momentum
+		10000, // MaxIterations
+		1e-15, // Tolerance
+		nil,
+	}
+}
+
+// SetBatchSize switches to mini-batch gradient descent, where each update uses only
+// batchSize randomly chosen samples. This is much faster per iteration on long recordings,
+// at the cost of noisier updates, so Tolerance will likely need raising to converge.
+// 0 (the default) uses every sample for every update.
+func (ml *GradDescLinReg) SetBatchSize(batchSize int) {
+	if batchSize < 0 {
+		panic("Batch size can't be negative")
+	}
+	ml.batchSize = batchSize
+}
+
+// SetMomentum makes each update v = momentum * v - alpha * gradient, which damps the
+// zig-zagging of plain gradient descent on badly conditioned inputs. 0 (the default)
+// is plain gradient descent.
+func (ml *GradDescLinReg) SetMomentum(momentum float64) {
+	if momentum < 0 || momentum >= 1 {
+		panic("Momentum must be in [0, 1)")
 	}
+	ml.momentum = momentum
 }
 
 // Train performs gradient descent on the given data to find the linear regression.
-func (ml *GradDescLinReg) Train(inputs []float64, training []float64) GDLRState {
+// If it doesn't converge within MaxIterations, the last state is returned along with
+// a *ConvergenceError, so the caller can e.g. retry with a smaller alpha. The same error
+// is returned straight away if the updates diverge to infinity or NaN.
+func (ml *GradDescLinReg) Train(inputs []float64, training []float64) (GDLRState, error) {
 	if len(inputs) != len(training) {
 		panic("Inputs to train must be the same size")
 	}
 
 	ml.state[0], ml.state[1] = 0.0, 0.0
+	velocity := [...]float64{0., 0.}
 	
 	iterations := 0
 	updateDistSq := 1.0
 
-	for updateDistSq > 1e-15 {
-		if iterations % 1000 == 0 {
-			fmt.Printf("#%d\t:\t%f - %f\n", iterations, ml.state[0], ml.state[1])
+	for updateDistSq > ml.Tolerance {
+		if ml.OnIteration != nil {
+			ml.OnIteration(iterations, ml.state)
 		}
-		if iterations > 10000 {
-			panic("No convergence")
+		if iterations > ml.MaxIterations {
+			return ml.state, &ConvergenceError{iterations, updateDistSq}
 		}
 		iterations++
+		updateDistSq = ml.step(inputs, training, &velocity)
+		if math.IsNaN(updateDistSq) || math.IsInf(updateDistSq, 0) {
+			// Diverged, so there's no point continuing.
+			return ml.state, &ConvergenceError{iterations, updateDistSq}
+		}
+	}
+	return ml.state, nil
+}
 
-		nextState := [...]float64{0., 0.}
-		nextState[0] = ml.state[0] - ml.alpha * ml.meanDist(inputs, training)
-		nextState[1] = ml.state[1] - ml.alpha * ml.meanScaledDist(inputs, training)
-		updateDistSq = DistSq(ml.state[:], nextState[:])
-		ml.state = nextState
+// TrainWithValidation performs gradient descent like Train, but also tracks the mean squared
+// error on a separate validation set, stopping early once that hasn't improved for patience
+// iterations in a row, as further steps are then overfitting the training data.
+// Returns the state with the lowest validation error, rather than the last one. A
+// *ConvergenceError is returned if MaxIterations is hit while validation is improving,
+// or if the updates diverge to infinity or NaN.
+func (ml *GradDescLinReg) TrainWithValidation(inputs []float64, training []float64,
+	valInputs []float64, valTraining []float64, patience int) (GDLRState, error) {
+	if len(inputs) != len(training) || len(valInputs) != len(valTraining) {
+		panic("Inputs to train must be the same size")
 	}
-	return ml.state
+	if len(valInputs) == 0 {
+		panic("Validation set must not be empty")
+	}
+	if patience < 1 {
+		panic("Patience must be at least 1")
+	}
+
+	ml.state[0], ml.state[1] = 0.0, 0.0
+	velocity := [...]float64{0., 0.}
+	best, bestError, sinceBest := ml.state, ml.meanSqError(valInputs, valTraining), 0
+
+	iterations := 0
+	updateDistSq := 1.0
+
+	for updateDistSq > ml.Tolerance && sinceBest < patience {
+		if ml.OnIteration != nil {
+			ml.OnIteration(iterations, ml.state)
+		}
+		if iterations > ml.MaxIterations {
+			ml.state = best
+			return best, &ConvergenceError{iterations, updateDistSq}
+		}
+		iterations++
+		updateDistSq = ml.step(inputs, training, &velocity)
+		if math.IsNaN(updateDistSq) || math.IsInf(updateDistSq, 0) {
+			ml.state = best
+			return best, &ConvergenceError{iterations, updateDistSq}
+		}
+
+		if valError := ml.meanSqError(valInputs, valTraining); valError < bestError {
+			best, bestError, sinceBest = ml.state, valError, 0
+		} else {
+			sinceBest++
+		}
+	}
+	ml.state = best
+	return best, nil
+}
+
+// step performs a single gradient descent update, returning the squared distance moved.
+func (ml *GradDescLinReg) step(inputs []float64, training []float64, velocity *[2]float64) float64 {
+	batchInputs, batchTraining := ml.pickBatch(inputs, training)
+	velocity[0] = ml.momentum * velocity[0] - ml.alpha * ml.meanDist(batchInputs, batchTraining)
+	velocity[1] = ml.momentum * velocity[1] - ml.alpha * (ml.meanScaledDist(batchInputs, batchTraining) + ml.lambda * ml.state[1])
+	nextState := [...]float64{ml.state[0] + velocity[0], ml.state[1] + velocity[1]}
+	updateDistSq := DistSq(ml.state[:], nextState[:])
+	ml.state = nextState
+	return updateDistSq
+}
+
+// meanSqError is the mean squared error of the current state over the given data.
+func (ml *GradDescLinReg) meanSqError(inputs []float64, training []float64) float64 {
+	total := 0.0
+	for i := range inputs {
+		delta := ml.estimate(inputs[i]) - training[i]
+		total += delta * delta
+	}
+	return total / float64(len(inputs))
+}
+
+// pickBatch returns the samples to use for the next update: all of them for full batch
+// descent, otherwise batchSize chosen at random (with replacement).
+func (ml *GradDescLinReg) pickBatch(inputs []float64, training []float64) ([]float64, []float64) {
+	if ml.batchSize == 0 || ml.batchSize >= len(inputs) {
+		return inputs, training
+	}
+	batchInputs, batchTraining := make([]float64, ml.batchSize), make([]float64, ml.batchSize)
+	for i := range batchInputs {
+		at := ml.rng.Intn(len(inputs))
+		batchInputs[i], batchTraining[i] = inputs[at], training[at]
+	}
+	return batchInputs, batchTraining
 }
 
 func (ml *GradDescLinReg) meanDist(inputs []float64, training []float64) float64 {
@@ -71,3 +222,17 @@ func (ml *GradDescLinReg) meanScaledDist(inputs []float64, training []float64) f
 func (ml *GradDescLinReg) estimate(input float64) float64 {
 	return ml.state[0] + ml.state[1] * input
 }
+
+// Predict returns the fitted line's value at x, using the state from the last Train.
+func (ml *GradDescLinReg) Predict(x float64) float64 {
+	return ml.estimate(x)
+}
+
+// PredictAll returns the fitted line's value at each of the xs.
+func (ml *GradDescLinReg) PredictAll(xs []float64) []float64 {
+	result := make([]float64, len(xs), len(xs))
+	for i, x := range xs {
+		result[i] = ml.estimate(x)
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package ml
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrainWithValidationReturnsBestState(t *testing.T) {
+	// Training data is y = 2x, but validation is y = x, so the validation error is lowest
+	// part way through descent, then rises as the slope heads on towards 2.
+	inputs, training := []float64{1, 2, 3}, []float64{2, 4, 6}
+	valInputs, valTraining := []float64{1, 2}, []float64{1, 2}
+
+	ml := NewGradDescLinReg(0.01)
+	bestError := -1.0
+	ml.OnIteration = func(iter int, state GDLRState) {
+		check := NewGradDescLinReg(0.01)
+		check.state = state
+		if err := check.meanSqError(valInputs, valTraining); bestError < 0 || err < bestError {
+			bestError = err
+		}
+	}
+	state, err := ml.TrainWithValidation(inputs, training, valInputs, valTraining, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := ml.meanSqError(valInputs, valTraining); got != bestError {
+		t.Errorf("Expected the best validation error %g, got %g for state %v", bestError, got, state)
+	}
+	if state[1] > 1.5 {
+		t.Errorf("Expected to stop early with a slope near 1, got %v", state)
+	}
+
+	converged := NewGradDescLinReg(0.01)
+	converged.Train(inputs, training)
+	if converged.meanSqError(valInputs, valTraining) <= bestError {
+		t.Errorf("Expected early stopping to beat full training on validation data")
+	}
+}
+
+func TestTrainWithValidationPanicsWithoutPatience(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for patience 0")
+		}
+	}()
+	NewGradDescLinReg(0.01).TrainWithValidation([]float64{1}, []float64{1}, []float64{1}, []float64{1}, 0)
+}
+
+func TestTrainDiverges(t *testing.T) {
+	// Each step overshoots by more than it corrects, so the state blows up.
+	_, err := NewGradDescLinReg(1.0).Train([]float64{1, 2, 3}, []float64{2, 4, 6})
+	var convergenceErr *ConvergenceError
+	if !errors.As(err, &convergenceErr) {
+		t.Fatalf("Expected a ConvergenceError for a diverging alpha, got %v", err)
+	}
+	if convergenceErr.Iterations >= 10000 {
+		t.Errorf("Expected to stop as soon as the updates diverged, took %d iterations", convergenceErr.Iterations)
+	}
+}
+
+func TestTrainWithValidationDiverges(t *testing.T) {
+	// With enough patience, validation can't stop the run before the updates blow up.
+	inputs, training := []float64{1, 2, 3}, []float64{2, 4, 6}
+	state, err := NewGradDescLinReg(1.0).TrainWithValidation(inputs, training, inputs, training, 100000)
+	var convergenceErr *ConvergenceError
+	if !errors.As(err, &convergenceErr) {
+		t.Fatalf("Expected a ConvergenceError for a diverging alpha, got %v", err)
+	}
+	if state != (GDLRState{0, 0}) {
+		t.Errorf("Expected the best state to be the starting one, got %v", state)
+	}
+}
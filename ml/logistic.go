@@ -0,0 +1,103 @@
+// One-dimensional logistic regression, for mapping a score to a probability.
+
+package ml
+
+import (
+	"math"
+)
+
+// Logistic maps x to the probability 1 / (1 + e^-(Bias + Slope * x)).
+type Logistic struct {
+	Bias  float64
+	Slope float64
+}
+
+// Newton's method stops after this many iterations, or once a step is smaller than the tolerance.
+const (
+	logisticMaxIterations = 100
+	logisticTolerance     = 1e-10
+)
+
+// FitLogistic finds the logistic curve maximizing the likelihood of the [0, 1] labels given
+// the inputs, by Newton's method with backtracking. As in Platt scaling, the targets are
+// smoothed slightly away from 0 and 1 based on how many of each label there are, so that
+// perfectly separable inputs still give a finite slope.
+func FitLogistic(inputs []float64, labels []int) Logistic {
+	if len(inputs) != len(labels) {
+		panic("Inputs to fit must be the same size")
+	}
+	positives := 0
+	for _, label := range labels {
+		positives += label
+	}
+	negatives := len(labels) - positives
+	hiTarget := (float64(positives) + 1) / (float64(positives) + 2)
+	loTarget := 1 / (float64(negatives) + 2)
+
+	targets := make([]float64, len(labels), len(labels))
+	for i, label := range labels {
+		targets[i] = loTarget
+		if label == 1 {
+			targets[i] = hiTarget
+		}
+	}
+
+	// Start from the prior, with no dependence on the input.
+	l := Logistic{math.Log((float64(positives) + 1) / (float64(negatives) + 1)), 0}
+	loss := l.negLogLikelihood(inputs, targets)
+	for iter := 0; iter < logisticMaxIterations; iter++ {
+		// Gradient and Hessian of the negative log likelihood.
+		gBias, gSlope := 0.0, 0.0
+		hBB, hBS, hSS := 0.0, 0.0, 0.0
+		for i, x := range inputs {
+			target := targets[i]
+			p := l.Predict(x)
+			gBias += p - target
+			gSlope += (p - target) * x
+			w := p * (1 - p)
+			hBB += w
+			hBS += w * x
+			hSS += w * x * x
+		}
+
+		det := hBB*hSS - hBS*hBS
+		if det <= 0 {
+			break
+		}
+		stepBias := (hSS*gBias - hBS*gSlope) / det
+		stepSlope := (hBB*gSlope - hBS*gBias) / det
+
+		// Halve the step until it improves the fit, as a full step can overshoot.
+		for ; stepBias*stepBias+stepSlope*stepSlope >= logisticTolerance; stepBias, stepSlope = stepBias/2, stepSlope/2 {
+			next := Logistic{l.Bias - stepBias, l.Slope - stepSlope}
+			if nextLoss := next.negLogLikelihood(inputs, targets); nextLoss < loss {
+				l, loss = next, nextLoss
+				break
+			}
+		}
+		if stepBias*stepBias+stepSlope*stepSlope < logisticTolerance {
+			break
+		}
+	}
+	return l
+}
+
+// negLogLikelihood is the cross entropy between the targets and the predictions.
+func (l Logistic) negLogLikelihood(inputs []float64, targets []float64) float64 {
+	total := 0.0
+	for i, x := range inputs {
+		// log(1 + e^z) - target * z, rearranged to not overflow for large z.
+		z := l.Bias + l.Slope*x
+		if z > 0 {
+			total += z + math.Log1p(math.Exp(-z)) - targets[i]*z
+		} else {
+			total += math.Log1p(math.Exp(z)) - targets[i]*z
+		}
+	}
+	return total
+}
+
+// Predict returns the probability for a single input.
+func (l Logistic) Predict(x float64) float64 {
+	return 1 / (1 + math.Exp(-(l.Bias + l.Slope*x)))
+}
@@ -0,0 +1,94 @@
+// Closed-form least squares linear regression, solving the normal equation
+// rather than descending the gradient.
+
+package ml
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrSingular is returned when XᵀX can't be inverted, e.g. when one input is a
+// linear combination of the others, or there are fewer rows than coefficients.
+var ErrSingular = errors.New("XᵀX is singular, no unique least squares solution")
+
+// Pivots smaller than this (relative to the largest entry) are treated as zero.
+const singularTolerance = 1e-12
+
+// FitNormalEquation finds the exact least squares coefficients by solving
+// (XᵀX)⁻¹ Xᵀy, where X is the inputs with a leading bias column of 1s.
+// The result has the bias first, then one coefficient per input feature.
+func FitNormalEquation(inputs [][]float64, training []float64) ([]float64, error) {
+	if len(inputs) != len(training) {
+		panic("Inputs to fit must be the same size")
+	}
+	if len(inputs) == 0 {
+		return nil, ErrSingular
+	}
+
+	n := len(inputs[0]) + 1
+	// Augmented matrix [XᵀX | Xᵀy]
+	a := make([][]float64, n, n)
+	for i := range a {
+		a[i] = make([]float64, n+1, n+1)
+	}
+	row := make([]float64, n, n)
+	for r, input := range inputs {
+		if len(input) != n-1 {
+			panic("All inputs must have the same number of features")
+		}
+		row[0] = 1.0
+		copy(row[1:], input)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += row[i] * row[j]
+			}
+			a[i][n] += row[i] * training[r]
+		}
+	}
+	return solve(a)
+}
+
+// solve performs Gauss-Jordan elimination with partial pivoting on an
+// n x (n + 1) augmented matrix, returning the solution column.
+func solve(a [][]float64) ([]float64, error) {
+	n := len(a)
+	largest := 0.0
+	for i := range a {
+		for j := 0; j < n; j++ {
+			largest = math.Max(largest, math.Abs(a[i][j]))
+		}
+	}
+	if largest == 0 {
+		return nil, ErrSingular
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < singularTolerance*largest {
+			return nil, ErrSingular
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := 0; r < n; r++ {
+			if r == col {
+				continue
+			}
+			factor := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= factor * a[col][c]
+			}
+		}
+	}
+
+	result := make([]float64, n, n)
+	for i := range result {
+		result[i] = a[i][n] / a[i][i]
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package ml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitNormalEquation(t *testing.T) {
+	// y = 1 + 2a - 3b exactly.
+	inputs := [][]float64{{0, 0}, {1, 0}, {0, 1}, {2, 3}, {5, -1}}
+	training := []float64{1, 3, -2, -4, 14}
+	fit, err := FitNormalEquation(inputs, training)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, expected := range []float64{1, 2, -3} {
+		if math.Abs(fit[i]-expected) > 1e-9 {
+			t.Errorf("Coefficient %d: expected %f, got %f", i, expected, fit[i])
+		}
+	}
+
+	// Second feature is a copy of the first.
+	if _, err := FitNormalEquation([][]float64{{1, 1}, {2, 2}, {3, 3}}, []float64{1, 2, 3}); err != ErrSingular {
+		t.Errorf("Expected ErrSingular, got %v", err)
+	}
+}
+
+func TestGradDescMatchesNormalEquation(t *testing.T) {
+	xs := []float64{0.0, 0.5, 1.0, 1.5, 2.0, 2.5}
+	ys := []float64{0.1, 0.4, 1.2, 1.4, 2.1, 2.4}
+	inputs := make([][]float64, len(xs))
+	for i, x := range xs {
+		inputs[i] = []float64{x}
+	}
+
+	exact, err := FitNormalEquation(inputs, ys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	descent, err := NewGradDescLinReg(0.1).Train(xs, ys)
+	if err != nil {
+		t.Fatalf("Gradient descent failed: %v", err)
+	}
+	for i := range descent {
+		if math.Abs(descent[i]-exact[i]) > 1e-5 {
+			t.Errorf("Coefficient %d: descent gave %f, normal equation %f", i, descent[i], exact[i])
+		}
+	}
+}
+
+func TestPolyFeaturesGradDesc(t *testing.T) {
+	// y = 0.5 - x + 2x^2, over [-1, 1].
+	xs := []float64{-1, -0.6, -0.2, 0.2, 0.6, 1}
+	ys := make([]float64, len(xs))
+	for i, x := range xs {
+		ys[i] = 0.5 - x + 2*x*x
+	}
+
+	fit, err := NewGradDescMultiLinReg(0.5).Train(PolyFeatures(xs, 2), ys)
+	if err != nil {
+		t.Fatalf("Gradient descent failed: %v", err)
+	}
+	for i, expected := range []float64{0.5, -1, 2} {
+		if math.Abs(fit[i]-expected) > 1e-5 {
+			t.Errorf("Coefficient %d: expected %f, got %f", i, expected, fit[i])
+		}
+	}
+}
+
+func TestRidgeGradDescMultiShrinksWeights(t *testing.T) {
+	xs := []float64{-1, -0.6, -0.2, 0.2, 0.6, 1}
+	ys := make([]float64, len(xs))
+	for i, x := range xs {
+		ys[i] = 0.5 - x + 2*x*x
+	}
+	weightNorm := func(weights []float64) float64 {
+		total := 0.0
+		for _, w := range weights[1:] {
+			total += w * w
+		}
+		return total
+	}
+
+	plain, err := NewGradDescMultiLinReg(0.5).Train(PolyFeatures(xs, 2), ys)
+	if err != nil {
+		t.Fatalf("Gradient descent failed: %v", err)
+	}
+	ridge, err := NewRidgeGradDescMultiLinReg(0.5, 0.5).Train(PolyFeatures(xs, 2), ys)
+	if err != nil {
+		t.Fatalf("Ridge gradient descent failed: %v", err)
+	}
+	if weightNorm(ridge) >= weightNorm(plain) {
+		t.Errorf("Expected ridge weights %v to be smaller than plain weights %v", ridge, plain)
+	}
+}
@@ -0,0 +1,137 @@
+// Multivariate linear regression by gradient descent, e.g. to fit polynomials
+// by regressing on PolyFeatures.
+
+package ml
+
+import (
+	"math"
+)
+
+// PolyFeatures expands each scalar input into [1, x, x^2, ..., x^degree], so that a linear
+// regression on the result fits a polynomial of that degree. The leading 1 acts as the bias.
+//
+// Powers grow quickly, so for large |x| the columns differ in scale by orders of magnitude,
+// which makes gradient descent need a tiny alpha and converge very slowly. Standardize or
+// rescale x to roughly [-1, 1] first. Even then, above degree ~6 the columns become nearly
+// collinear, and the fit is ill-conditioned and sensitive to noise.
+func PolyFeatures(x []float64, degree int) [][]float64 {
+	if degree < 0 {
+		panic("Polynomial degree can't be negative")
+	}
+	result := make([][]float64, len(x), len(x))
+	for i, v := range x {
+		result[i] = make([]float64, degree+1, degree+1)
+		power := 1.0
+		for d := range result[i] {
+			result[i][d] = power
+			power *= v
+		}
+	}
+	return result
+}
+
+// GradDescMultiLinReg fits weights for y = w . x by gradient descent, for inputs with any
+// number of features. There is no separate bias: include a constant column for one,
+// as PolyFeatures does.
+type GradDescMultiLinReg struct {
+	weights []float64
+	alpha   float64
+	// L2 regularization strength, applied to all but weights[0], which is taken to be the bias.
+	lambda float64
+
+	// Train gives up after this many iterations without converging.
+	MaxIterations int
+	// Train has converged once the squared distance between updates is below this.
+	Tolerance float64
+}
+
+// State for performing multivariate linear regression by gradient descent.
+func NewGradDescMultiLinReg(alpha float64) *GradDescMultiLinReg {
+	return NewRidgeGradDescMultiLinReg(alpha, 0.0)
+}
+
+// State for performing multivariate ridge regression by gradient descent, like
+// NewRidgeGradDescLinReg. The first feature is the bias, e.g. PolyFeatures' constant
+// column, so is not regularized.
+func NewRidgeGradDescMultiLinReg(alpha float64, lambda float64) *GradDescMultiLinReg {
+	return &GradDescMultiLinReg{
+		nil,
+		alpha,
+		lambda,
+		10000, // MaxIterations
+		1e-15, // Tolerance
+	}
+}
+
+// Train performs gradient descent to find the weights minimizing the mean squared error.
+// Like GradDescLinReg.Train, if it doesn't converge the last weights are returned along
+// with a *ConvergenceError.
+func (ml *GradDescMultiLinReg) Train(inputs [][]float64, training []float64) ([]float64, error) {
+	if len(inputs) != len(training) {
+		panic("Inputs to train must be the same size")
+	}
+	if len(inputs) == 0 {
+		panic("Train needs at least one input")
+	}
+	features := len(inputs[0])
+	for _, input := range inputs {
+		if len(input) != features {
+			panic("All inputs must have the same number of features")
+		}
+	}
+
+	ml.weights = make([]float64, features, features)
+	gradient := make([]float64, features, features)
+	iterations := 0
+	updateDistSq := 1.0
+
+	for updateDistSq > ml.Tolerance {
+		if iterations > ml.MaxIterations {
+			return ml.Weights(), &ConvergenceError{iterations, updateDistSq}
+		}
+		iterations++
+
+		for f := range gradient {
+			gradient[f] = 0
+		}
+		for i, input := range inputs {
+			delta := ml.Predict(input) - training[i]
+			for f, v := range input {
+				gradient[f] += delta * v
+			}
+		}
+
+		updateDistSq = 0
+		for f := range ml.weights {
+			grad := gradient[f] / float64(len(inputs))
+			if f > 0 {
+				grad += ml.lambda * ml.weights[f]
+			}
+			step := ml.alpha * grad
+			ml.weights[f] -= step
+			updateDistSq += step * step
+		}
+		if math.IsNaN(updateDistSq) || math.IsInf(updateDistSq, 0) {
+			// Diverged, so there's no point continuing.
+			return ml.Weights(), &ConvergenceError{iterations, updateDistSq}
+		}
+	}
+	return ml.Weights(), nil
+}
+
+// Weights returns a copy of the weights from the last Train.
+func (ml *GradDescMultiLinReg) Weights() []float64 {
+	return append([]float64(nil), ml.weights...)
+}
+
+// Predict returns the fitted value for a single input.
+func (ml *GradDescMultiLinReg) Predict(input []float64) float64 {
+	if len(input) != len(ml.weights) {
+		panic("Input has a different number of features to the trained weights")
+	}
+	result := 0.0
+	for f, v := range input {
+		result += ml.weights[f] * v
+	}
+	return result
+}
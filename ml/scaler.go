@@ -0,0 +1,83 @@
+package ml
+
+import (
+	"math"
+)
+
+// Scaler standardizes each input feature to zero mean and unit standard deviation.
+// Fit it on training data, then apply the same Transform to test data.
+type Scaler struct {
+	// Per-feature mean and standard deviation, set by Fit.
+	Mean []float64
+	Std  []float64
+}
+
+// Fit calculates the mean and (population) standard deviation of each feature.
+// Constant features are given a std of 1, so they transform to 0 rather than NaN.
+func (s *Scaler) Fit(inputs [][]float64) {
+	if len(inputs) == 0 {
+		panic("Scaler needs at least one input to fit")
+	}
+	features := len(inputs[0])
+	s.Mean = make([]float64, features, features)
+	s.Std = make([]float64, features, features)
+
+	for _, input := range inputs {
+		s.checkSize(input)
+		for f, v := range input {
+			s.Mean[f] += v
+		}
+	}
+	n := float64(len(inputs))
+	for f := range s.Mean {
+		s.Mean[f] /= n
+	}
+
+	for _, input := range inputs {
+		for f, v := range input {
+			delta := v - s.Mean[f]
+			s.Std[f] += delta * delta
+		}
+	}
+	for f := range s.Std {
+		s.Std[f] = math.Sqrt(s.Std[f] / n)
+		if s.Std[f] == 0 {
+			s.Std[f] = 1
+		}
+	}
+}
+
+// Transform returns standardized copies of the inputs, using the fitted parameters.
+func (s *Scaler) Transform(inputs [][]float64) [][]float64 {
+	result := make([][]float64, len(inputs), len(inputs))
+	for i, input := range inputs {
+		s.checkSize(input)
+		result[i] = make([]float64, len(input), len(input))
+		for f, v := range input {
+			result[i][f] = (v - s.Mean[f]) / s.Std[f]
+		}
+	}
+	return result
+}
+
+// InverseTransform maps standardized values back to the original scale.
+func (s *Scaler) InverseTransform(inputs [][]float64) [][]float64 {
+	result := make([][]float64, len(inputs), len(inputs))
+	for i, input := range inputs {
+		s.checkSize(input)
+		result[i] = make([]float64, len(input), len(input))
+		for f, v := range input {
+			result[i][f] = v*s.Std[f] + s.Mean[f]
+		}
+	}
+	return result
+}
+
+func (s *Scaler) checkSize(input []float64) {
+	if s.Mean == nil {
+		panic("Scaler must be fit before use")
+	}
+	if len(input) != len(s.Mean) {
+		panic("All inputs must have the same number of features as the Scaler")
+	}
+}
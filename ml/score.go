@@ -0,0 +1,29 @@
+package ml
+
+// RSquared is the coefficient of determination, 1 - SS_res / SS_tot, of the predictions.
+// 1 is a perfect fit, 0 is no better than predicting the mean, and it can be negative.
+// If all the actual values are equal (SS_tot = 0) it is undefined, and 0 is returned.
+func RSquared(actual []float64, predicted []float64) float64 {
+	if len(actual) != len(predicted) {
+		panic("RSquared requires actual and predicted to be the same size")
+	}
+	if len(actual) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range actual {
+		mean += v
+	}
+	mean /= float64(len(actual))
+
+	ssRes, ssTot := 0.0, 0.0
+	for i, v := range actual {
+		ssRes += (v - predicted[i]) * (v - predicted[i])
+		ssTot += (v - mean) * (v - mean)
+	}
+	if ssTot == 0 {
+		return 0
+	}
+	return 1 - ssRes/ssTot
+}
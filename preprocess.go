@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+
+	"github.com/padster/eego/signal"
+)
+
+// ZScore returns the channel's samples standardized to zero mean and unit standard deviation.
+// A constant channel has no spread, so becomes all zeros.
+func (c Channel) ZScore() []float64 {
+	result := make([]float64, len(c.Samples), len(c.Samples))
+	if len(c.Samples) == 0 {
+		return result
+	}
+
+	mean := 0.0
+	for _, v := range c.Samples {
+		mean += float64(v)
+	}
+	mean /= float64(len(c.Samples))
+	variance := 0.0
+	for _, v := range c.Samples {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	std := math.Sqrt(variance / float64(len(c.Samples)))
+	if std == 0 {
+		std = 1
+	}
+
+	for i, v := range c.Samples {
+		result[i] = (float64(v) - mean) / std
+	}
+	return result
+}
+
+// MinMaxScale returns the channel's samples linearly mapped so its minimum becomes lo and
+// its maximum becomes hi. A constant channel maps entirely to lo.
+func (c Channel) MinMaxScale(lo float64, hi float64) []float64 {
+	if len(c.Samples) == 0 {
+		return []float64{}
+	}
+	min, max := minMax(c.Samples)
+	return scaleClipped(c.Samples, min, max, lo, hi)
+}
+
+// scaleClipped linearly maps samples in [min, max] to [lo, hi], clipping any outside that range.
+func scaleClipped(samples []int, min int, max int, lo float64, hi float64) []float64 {
+	result := make([]float64, len(samples), len(samples))
+	if max == min {
+		max = min + 1
+	}
+	for i, s := range samples {
+		if s < min {
+			s = min
+		} else if s > max {
+			s = max
+		}
+		result[i] = lo + (hi-lo)*float64(s-min)/float64(max-min)
+	}
+	return result
+}
+
+// CommonAverageReference re-references each channel by subtracting the mean across
+// all channels at each sample, removing noise shared by every electrode.
+// The input channels are left untouched, and new channels are returned, with samples
+// rounded to the nearest integer. See CommonAverageReferenceFloat to avoid rounding.
+func CommonAverageReference(chs []Channel) []Channel {
+	floats := CommonAverageReferenceFloat(chs)
+	result := make([]Channel, len(floats), len(floats))
+	for i, c := range floats {
+		result[i] = Channel{c.Id, make([]int, len(c.Samples), len(c.Samples))}
+		for s, v := range c.Samples {
+			result[i].Samples[s] = int(math.Floor(v + 0.5))
+		}
+	}
+	return result
+}
+
+// CommonAverageReferenceFloat is CommonAverageReference without rounding the results,
+// so that no precision is lost when the mean isn't a whole number.
+func CommonAverageReferenceFloat(chs []Channel) []FloatChannel {
+	result := make([]FloatChannel, len(chs), len(chs))
+	if len(chs) == 0 {
+		return result
+	}
+
+	n := len(chs[0].Samples)
+	for _, c := range chs {
+		if len(c.Samples) != n {
+			panic("CommonAverageReference requires channels of the same length")
+		}
+	}
+
+	for i, c := range chs {
+		result[i] = FloatChannel{c.Id, make([]float64, n, n)}
+	}
+	for s := 0; s < n; s++ {
+		sum := 0
+		for _, c := range chs {
+			sum += c.Samples[s]
+		}
+		mean := float64(sum) / float64(len(chs))
+		for i, c := range chs {
+			result[i].Samples[s] = float64(c.Samples[s]) - mean
+		}
+	}
+	return result
+}
+
+// DownsampleChannels keeps every factor'th sample of each channel, without filtering, so it
+// is safe for 0/1 event channels. Data channels downsampled with this or with
+// DownsampleChannelsFiltered keep the same sample indices, so they stay aligned with events.
+func DownsampleChannels(chs []Channel, factor int) []Channel {
+	result := make([]Channel, len(chs), len(chs))
+	for i, c := range chs {
+		result[i] = Channel{c.Id, signal.Downsample(c.Samples, factor)}
+	}
+	return result
+}
+
+// DownsampleChannelsFiltered low-passes each channel before downsampling, to avoid aliasing.
+// This should be used for data channels, not event channels.
+func DownsampleChannelsFiltered(chs []Channel, factor int) []Channel {
+	result := make([]Channel, len(chs), len(chs))
+	for i, c := range chs {
+		result[i] = Channel{c.Id, signal.DownsampleFiltered(c.Samples, factor)}
+	}
+	return result
+}
+
+// SplitByTime splits every channel at the same sample, with the first trainFraction of the
+// recording for training and the rest for testing. Neighbouring EEG samples are highly
+// correlated, so a shuffled split would leak information between the two, and a contiguous
+// split is needed for an honest test score. The results share the input channels' samples.
+func SplitByTime(chs []Channel, trainFraction float64) (train, test []Channel) {
+	if trainFraction < 0 || trainFraction > 1 {
+		panic("SplitByTime requires a train fraction between 0 and 1")
+	}
+	train, test = make([]Channel, len(chs), len(chs)), make([]Channel, len(chs), len(chs))
+	if len(chs) == 0 {
+		return train, test
+	}
+
+	n := len(chs[0].Samples)
+	at := int(trainFraction * float64(n))
+	for i, c := range chs {
+		if len(c.Samples) != n {
+			panic("SplitByTime requires channels of the same length")
+		}
+		// Limit the capacity, so appending to a training channel can't overwrite test samples.
+		train[i] = Channel{c.Id, c.Samples[:at:at]}
+		test[i] = Channel{c.Id, c.Samples[at:]}
+	}
+	return train, test
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Result is how well a forest trained on one EEG channel detected one event.
+type Result struct {
+	// Which recordings the forest was trained and graded on.
+	Subject     int
+	TrainSeries int
+	TestSeries  int
+
+	DataChannel  string
+	EventChannel string
+	// Size and average training errors of the trained forest.
+	Nodes    int
+	AvgError float64
+	// ROC AUC on held-out data, NaN if it couldn't be scored.
+	Auc float64
+}
+
+// WriteResults writes one CSV row per result, after a header row naming the columns.
+func WriteResults(w io.Writer, rows []Result) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"subject", "train_series", "test_series", "data_channel", "event_channel", "nodes", "avg_error", "auc"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		record := []string{
+			strconv.Itoa(r.Subject),
+			strconv.Itoa(r.TrainSeries),
+			strconv.Itoa(r.TestSeries),
+			r.DataChannel,
+			r.EventChannel,
+			strconv.Itoa(r.Nodes),
+			strconv.FormatFloat(r.AvgError, 'g', -1, 64),
+			strconv.FormatFloat(r.Auc, 'g', -1, 64),
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
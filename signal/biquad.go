@@ -0,0 +1,71 @@
+// Package signal contains preprocessing for raw EEG samples, e.g. filtering,
+// before they are used as features.
+package signal
+
+import (
+	"math"
+)
+
+// biquad is a second order IIR filter section, with coefficients normalized so a0 = 1.
+// Coefficient formulas are from Robert Bristow-Johnson's Audio EQ Cookbook:
+// https://www.w3.org/TR/audio-eq-cookbook/
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+}
+
+// Q giving a maximally flat (Butterworth) response for a single section.
+var butterworthQ = 1 / math.Sqrt2
+
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{b0 / a0, b1 / a0, b2 / a0, a1 / a0, a2 / a0}
+}
+
+// lowPass passes frequencies below cutoffHz.
+func lowPass(sampleRateHz, cutoffHz, q float64) biquad {
+	w0 := angularFrequency(sampleRateHz, cutoffHz)
+	alpha := math.Sin(w0) / (2 * q)
+	cosW0 := math.Cos(w0)
+	return newBiquad((1-cosW0)/2, 1-cosW0, (1-cosW0)/2, 1+alpha, -2*cosW0, 1-alpha)
+}
+
+// highPass passes frequencies above cutoffHz.
+func highPass(sampleRateHz, cutoffHz, q float64) biquad {
+	w0 := angularFrequency(sampleRateHz, cutoffHz)
+	alpha := math.Sin(w0) / (2 * q)
+	cosW0 := math.Cos(w0)
+	return newBiquad((1+cosW0)/2, -(1 + cosW0), (1+cosW0)/2, 1+alpha, -2*cosW0, 1-alpha)
+}
+
+// notch removes a narrow band around centerHz, with width centerHz / q.
+func notch(sampleRateHz, centerHz, q float64) biquad {
+	w0 := angularFrequency(sampleRateHz, centerHz)
+	alpha := math.Sin(w0) / (2 * q)
+	cosW0 := math.Cos(w0)
+	return newBiquad(1, -2*cosW0, 1, 1+alpha, -2*cosW0, 1-alpha)
+}
+
+// apply runs the filter over the samples, starting from a zero state.
+func (bq biquad) apply(samples []float64) []float64 {
+	result := make([]float64, len(samples), len(samples))
+	x1, x2, y1, y2 := 0.0, 0.0, 0.0, 0.0
+	for i, x := range samples {
+		y := bq.b0*x + bq.b1*x1 + bq.b2*x2 - bq.a1*y1 - bq.a2*y2
+		x1, x2 = x, x1
+		y1, y2 = y, y1
+		result[i] = y
+	}
+	return result
+}
+
+// angularFrequency converts a frequency to radians per sample, panicking unless it's
+// strictly between 0 and the Nyquist frequency.
+func angularFrequency(sampleRateHz, hz float64) float64 {
+	if sampleRateHz <= 0 {
+		panic("Sample rate must be positive")
+	}
+	if hz <= 0 || hz >= sampleRateHz/2 {
+		panic("Filter frequency must be between 0 and half the sample rate")
+	}
+	return 2 * math.Pi * hz / sampleRateHz
+}
@@ -0,0 +1,45 @@
+package signal
+
+import (
+	"math"
+)
+
+// Downsample keeps every factor'th sample, starting with the first. There's no filtering,
+// so it's safe on 0/1 event flags, but frequencies above the new Nyquist rate will alias.
+// Use DownsampleFiltered on raw signals to avoid that.
+func Downsample(samples []int, factor int) []int {
+	if factor < 1 {
+		panic("Downsample factor must be at least 1")
+	}
+	result := make([]int, 0, (len(samples)+factor-1)/factor)
+	for i := 0; i < len(samples); i += factor {
+		result = append(result, samples[i])
+	}
+	return result
+}
+
+// DownsampleFiltered low-passes the samples below the new Nyquist rate before keeping every
+// factor'th sample, so that higher frequencies don't alias. The same samples are kept as
+// by Downsample, so events downsampled with that still line up. Results are rounded.
+func DownsampleFiltered(samples []int, factor int) []int {
+	if factor < 1 {
+		panic("Downsample factor must be at least 1")
+	}
+	if factor == 1 {
+		return append([]int(nil), samples...)
+	}
+
+	asFloat := make([]float64, len(samples), len(samples))
+	for i, v := range samples {
+		asFloat[i] = float64(v)
+	}
+	// Cut off a little below the new Nyquist rate, as the filter rolls off gradually.
+	// Frequencies are relative to a sample rate of 1.
+	filtered := lowPass(1, 0.4/float64(factor), butterworthQ).apply(asFloat)
+
+	result := make([]int, 0, (len(samples)+factor-1)/factor)
+	for i := 0; i < len(filtered); i += factor {
+		result = append(result, int(math.Floor(filtered[i]+0.5)))
+	}
+	return result
+}
@@ -0,0 +1,22 @@
+package signal
+
+// Epoch cuts a window of preSamples before to postSamples after each event onset, so each
+// epoch has preSamples + 1 + postSamples values with the onset at index preSamples.
+// Parts of a window outside the recording are zero-padded. Returns one epoch per event.
+func Epoch(samples []int, eventIndices []int, preSamples, postSamples int) [][]int {
+	if preSamples < 0 || postSamples < 0 {
+		panic("Epoch needs non-negative pre and post sample counts")
+	}
+	length := preSamples + 1 + postSamples
+	epochs := make([][]int, len(eventIndices), len(eventIndices))
+	for e, onset := range eventIndices {
+		epochs[e] = make([]int, length, length)
+		for i := range epochs[e] {
+			at := onset - preSamples + i
+			if 0 <= at && at < len(samples) {
+				epochs[e][i] = samples[at]
+			}
+		}
+	}
+	return epochs
+}
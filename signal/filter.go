@@ -0,0 +1,26 @@
+package signal
+
+// BandPass keeps the frequencies between lowHz and highHz, by running a second order
+// Butterworth high-pass at lowHz then a second order Butterworth low-pass at highHz.
+// The result is the same length as samples. The filter starts from rest, so the first
+// few multiples of sampleRateHz / lowHz samples include a settling transient.
+func BandPass(samples []float64, sampleRateHz, lowHz, highHz float64) []float64 {
+	if lowHz >= highHz {
+		panic("BandPass requires lowHz < highHz")
+	}
+	highPassed := highPass(sampleRateHz, lowHz, butterworthQ).apply(samples)
+	return lowPass(sampleRateHz, highHz, butterworthQ).apply(highPassed)
+}
+
+// Notch removes mains interference (or any other narrow band) at notchHz, using a second
+// order notch filter. Higher qualityFactor gives a narrower notch: the -3dB bandwidth is
+// notchHz / qualityFactor, so e.g. 30 removes roughly 58-62 Hz for 60 Hz mains.
+// The result is the same length as samples. The filter starts from rest, so roughly the
+// first qualityFactor * sampleRateHz / notchHz samples are still settling, during which
+// the hum is only partly removed.
+func Notch(samples []float64, sampleRateHz, notchHz, qualityFactor float64) []float64 {
+	if qualityFactor <= 0 {
+		panic("Notch quality factor must be positive")
+	}
+	return notch(sampleRateHz, notchHz, qualityFactor).apply(samples)
+}
@@ -0,0 +1,92 @@
+package signal
+
+import (
+	"math"
+	"testing"
+)
+
+// sine generates n samples of a unit sine wave at the given frequency.
+func sine(n int, sampleRateHz, hz float64) []float64 {
+	result := make([]float64, n, n)
+	for i := range result {
+		result[i] = math.Sin(2 * math.Pi * hz * float64(i) / sampleRateHz)
+	}
+	return result
+}
+
+// gain is the RMS of the second half of filtered, relative to the input, skipping the transient.
+func gain(input []float64, filtered []float64) float64 {
+	in, out := 0.0, 0.0
+	for i := len(input) / 2; i < len(input); i++ {
+		in += input[i] * input[i]
+		out += filtered[i] * filtered[i]
+	}
+	return math.Sqrt(out / in)
+}
+
+func TestBandPass(t *testing.T) {
+	cases := []struct {
+		hz      float64
+		minGain float64
+		maxGain float64
+	}{
+		{0.1, 0, 0.1},
+		{10, 0.95, 1.05},
+		{100, 0, 0.2},
+	}
+	for _, c := range cases {
+		input := sine(5000, 500, c.hz)
+		filtered := BandPass(input, 500, 0.5, 40)
+		if len(filtered) != len(input) {
+			t.Fatalf("Expected %d samples, got %d", len(input), len(filtered))
+		}
+		if g := gain(input, filtered); g < c.minGain || g > c.maxGain {
+			t.Errorf("%.1f Hz: gain %f outside [%f, %f]", c.hz, g, c.minGain, c.maxGain)
+		}
+	}
+}
+
+func TestNotch(t *testing.T) {
+	hum := sine(5000, 500, 60)
+	if g := gain(hum, Notch(hum, 500, 60, 30)); g > 0.01 {
+		t.Errorf("60 Hz gain %f, expected it to be removed", g)
+	}
+	wanted := sine(5000, 500, 10)
+	if g := gain(wanted, Notch(wanted, 500, 60, 30)); g < 0.99 {
+		t.Errorf("10 Hz gain %f, expected it to be kept", g)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	smoothed := MovingAverage([]int{3, 0, 6, 3, 9}, 3)
+	expected := []float64{1.5, 3, 3, 6, 6}
+	for i := range expected {
+		if math.Abs(smoothed[i]-expected[i]) > 1e-9 {
+			t.Errorf("Sample %d: expected %f, got %f", i, expected[i], smoothed[i])
+		}
+	}
+}
+
+func TestBandPower(t *testing.T) {
+	// 10 Hz with amplitude 1 has mean square 0.5, plus a DC offset of 2 with power 4.
+	frame := sine(250, 250, 10)
+	for i := range frame {
+		frame[i] += 2
+	}
+	power := BandPower(frame, 250, [][2]float64{{0, 1}, {8, 13}, {13, 30}})
+	expected := []float64{4, 0.5, 0}
+	for i := range expected {
+		if math.Abs(power[i]-expected[i]) > 1e-9 {
+			t.Errorf("Band %d: expected power %f, got %f", i, expected[i], power[i])
+		}
+	}
+}
+
+func TestBandPowerEmptyFrame(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected BandPower to panic on an empty frame")
+		}
+	}()
+	BandPower([]float64{}, 250, [][2]float64{{8, 13}})
+}
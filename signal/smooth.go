@@ -0,0 +1,38 @@
+package signal
+
+// MovingAverage smooths samples by replacing each with the mean of the window samples
+// centered on it. Near the edges the window shrinks to the samples that exist, rather
+// than padding with zeros. Runs in O(N) regardless of window size.
+func MovingAverage(samples []int, window int) []float64 {
+	asFloat := make([]float64, len(samples), len(samples))
+	for i, v := range samples {
+		asFloat[i] = float64(v)
+	}
+	return MovingAverageFloat(asFloat, window)
+}
+
+// MovingAverageFloat is MovingAverage for float samples.
+func MovingAverageFloat(samples []float64, window int) []float64 {
+	if window < 1 {
+		panic("Moving average window must be at least 1")
+	}
+	n := len(samples)
+	result := make([]float64, n, n)
+
+	// Window for sample i covers [i - before, i + after], clipped to the samples.
+	before := window / 2
+	after := window - 1 - before
+	sum, start, end := 0.0, 0, 0
+	for i := range result {
+		for end < n && end <= i+after {
+			sum += samples[end]
+			end++
+		}
+		for start < i-before {
+			sum -= samples[start]
+			start++
+		}
+		result[i] = sum / float64(end-start)
+	}
+	return result
+}
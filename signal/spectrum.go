@@ -0,0 +1,80 @@
+package signal
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// BandPower returns, for each [lowHz, highHz) band, the power of the frame within that band.
+// Power is the one-sided power spectrum from a DFT of the frame, scaled so that the band
+// powers over [0, Nyquist] sum to the mean squared value of the frame (Parseval).
+// Resolution is sampleRateHz / len(frame), so bands narrower than that may be empty.
+func BandPower(frame []float64, sampleRateHz float64, bands [][2]float64) []float64 {
+	if sampleRateHz <= 0 {
+		panic("Sample rate must be positive")
+	}
+	if len(frame) == 0 {
+		panic("BandPower needs a non-empty frame")
+	}
+	n := len(frame)
+	spectrum := make([]complex128, n, n)
+	for i, v := range frame {
+		spectrum[i] = complex(v, 0)
+	}
+	spectrum = fft(spectrum)
+
+	result := make([]float64, len(bands), len(bands))
+	for k := 0; k <= n/2; k++ {
+		power := real(spectrum[k])*real(spectrum[k]) + imag(spectrum[k])*imag(spectrum[k])
+		power /= float64(n * n)
+		if k > 0 && 2*k != n {
+			// Fold in the matching negative frequency.
+			power *= 2
+		}
+		hz := float64(k) * sampleRateHz / float64(n)
+		for b, band := range bands {
+			if band[0] <= hz && hz < band[1] {
+				result[b] += power
+			}
+		}
+	}
+	return result
+}
+
+// fft calculates the discrete Fourier transform, splitting recursively while the length is
+// even (Cooley-Tukey), and falling back to the direct O(N^2) sum for odd lengths.
+func fft(x []complex128) []complex128 {
+	n := len(x)
+	if n <= 1 {
+		return x
+	}
+	if n%2 == 1 {
+		return dft(x)
+	}
+
+	even, odd := make([]complex128, n/2), make([]complex128, n/2)
+	for i := 0; i < n/2; i++ {
+		even[i], odd[i] = x[2*i], x[2*i+1]
+	}
+	even, odd = fft(even), fft(odd)
+
+	result := make([]complex128, n, n)
+	for k := 0; k < n/2; k++ {
+		twiddled := cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n)) * odd[k]
+		result[k] = even[k] + twiddled
+		result[k+n/2] = even[k] - twiddled
+	}
+	return result
+}
+
+// dft is the direct discrete Fourier transform.
+func dft(x []complex128) []complex128 {
+	n := len(x)
+	result := make([]complex128, n, n)
+	for k := range result {
+		for t, v := range x {
+			result[k] += v * cmplx.Rect(1, -2*math.Pi*float64(k*t%n)/float64(n))
+		}
+	}
+	return result
+}
@@ -7,11 +7,19 @@ import (
 
 func main() {
 	gdlr := ml.NewGradDescLinReg(0.01)
+	gdlr.OnIteration = func(iter int, state ml.GDLRState) {
+		if iter % 1000 == 0 {
+			fmt.Printf("#%d\t:\t%f - %f\n", iter, state[0], state[1])
+		}
+	}
 
-	fit := gdlr.Train(
+	fit, err := gdlr.Train(
 		[]float64{9, 5, 12},
 		[]float64{2, 1, 3},
 	)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
 
 	fmt.Printf("Best fit: %f + %f * x\n", fit[0], fit[1])
 }
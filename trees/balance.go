@@ -0,0 +1,74 @@
+package trees
+
+import (
+	"math/rand"
+)
+
+// How many frames of 0s are kept together when undersampling, as a multiple of the frame
+// size. Each kept run also needs the frameSize - 1 samples before it for context, so longer
+// runs waste less, at the cost of coarser control over the ratio.
+const balanceRunFrames = 4
+
+// BalanceSamples undersamples frames of 0s (non-events) so that there are about ratio times
+// as many frames of 1s as 0s, e.g. 1.0 for equal numbers, ready to pass to Train.
+// Every frame of 1s is kept, along with random runs of 0s (chosen with the forest's seed),
+// and each kept frame keeps all of its samples, so features see exactly the same frames as
+// in the original recording. Where the kept parts are joined, the next frameSize - 1
+// frames will straddle the gap. These count towards the ratio, which is reached to within
+// one run of 0s, unless the context for the 1s alone has more 0s, e.g. for very short events.
+// If there are no 1s, or already too few 0s, copies of the inputs are returned.
+func (f *Forest) BalanceSamples(samples []int, expected []int, ratio float64) (bSamples []int, bExpected []int) {
+	if len(samples) != len(expected) {
+		panic("BalanceSamples requires samples and expected values to be the same size")
+	}
+	if ratio <= 0 {
+		panic("BalanceSamples requires a positive ratio")
+	}
+
+	// Which samples are kept, and how many of each label those are.
+	kept := make([]bool, len(samples), len(samples))
+	counts := [2]int{}
+	keepFrames := func(firstEnd int, lastEnd int) {
+		for i := firstEnd - f.frameSize + 1; i <= lastEnd; i++ {
+			if !kept[i] {
+				kept[i] = true
+				counts[expected[i]]++
+			}
+		}
+	}
+
+	totalOnes := 0
+	for end := f.frameSize - 1; end < len(samples); end++ {
+		if expected[end] == 1 {
+			keepFrames(end, end)
+			totalOnes++
+		}
+	}
+	if totalOnes == 0 || float64(len(samples)-totalOnes)*ratio <= float64(totalOnes) {
+		return append([]int{}, samples...), append([]int{}, expected...)
+	}
+
+	// Add random runs of frames until there are enough 0s.
+	runLength := balanceRunFrames * f.frameSize
+	runCount := (len(samples) - f.frameSize + runLength) / runLength
+	rng := rand.New(rand.NewSource(f.seed))
+	for _, run := range rng.Perm(runCount) {
+		if float64(counts[0])*ratio >= float64(counts[1]) {
+			break
+		}
+		firstEnd := f.frameSize - 1 + run*runLength
+		lastEnd := firstEnd + runLength - 1
+		if lastEnd >= len(samples) {
+			lastEnd = len(samples) - 1
+		}
+		keepFrames(firstEnd, lastEnd)
+	}
+
+	for i, keep := range kept {
+		if keep {
+			bSamples = append(bSamples, samples[i])
+			bExpected = append(bExpected, expected[i])
+		}
+	}
+	return bSamples, bExpected
+}
@@ -0,0 +1,40 @@
+package trees
+
+import (
+	"testing"
+)
+
+func TestBalanceSamples(t *testing.T) {
+	// Events last 100 samples, once every 1000, so their context adds few 0s.
+	samples, _ := syntheticData(10000)
+	expected := make([]int, len(samples))
+	for i := range expected {
+		if i%1000 >= 500 && i%1000 < 600 {
+			expected[i] = 1
+		}
+	}
+	f := NewForest(4, 1, 0)
+	bSamples, bExpected := f.BalanceSamples(samples, expected, 1.0)
+	if len(bSamples) != len(bExpected) {
+		t.Fatalf("Got %d samples but %d expected values", len(bSamples), len(bExpected))
+	}
+
+	ones, bOnes, bZeros := 0, 0, 0
+	for _, e := range expected {
+		ones += e
+	}
+	for _, e := range bExpected {
+		if e == 1 {
+			bOnes++
+		} else {
+			bZeros++
+		}
+	}
+	if bOnes != ones {
+		t.Errorf("Expected every one of the %d events to be kept, got %d", ones, bOnes)
+	}
+	// Whole runs of 0s are kept, so the totals are equal to within one run.
+	if bZeros < bOnes || bZeros-bOnes > balanceRunFrames*f.frameSize {
+		t.Errorf("Expected about as many 0s as the %d 1s, got %d", bOnes, bZeros)
+	}
+}
@@ -0,0 +1,25 @@
+package trees
+
+import (
+	"github.com/padster/eego/ml"
+)
+
+// Calibrate fits a logistic curve (Platt scaling) mapping the forest's averaged leaf
+// probabilities to calibrated ones, which Classify then returns instead. Leaf fractions
+// are typically over-confident on the training frames, so the samples here should be
+// held out from training, e.g. a later part of the recording. Training again removes the
+// calibration, and calling Calibrate again refits it from the raw probabilities.
+func (f *Forest) Calibrate(samples []int, expected []int) {
+	if len(samples) != len(expected) {
+		panic("Calibrate requires samples and expected values to be the same size")
+	}
+	if len(samples) < f.frameSize {
+		panic("Calibrate requires at least one full frame of samples")
+	}
+
+	f.calibration = nil
+	raw := f.ClassifyFloat(asFloats(samples))
+	// The first frameSize - 1 samples are classified from zero-padded frames, so skip them.
+	calibration := ml.FitLogistic(raw[f.frameSize-1:], expected[f.frameSize-1:])
+	f.calibration = &calibration
+}
@@ -0,0 +1,30 @@
+package trees
+
+import (
+	"testing"
+)
+
+func TestCalibrateIsMonotonic(t *testing.T) {
+	samples, expected := syntheticData(3000)
+	f := NewForest(4, 5, 5)
+	f.Train(samples[:2000], expected[:2000])
+	raw := f.Classify(samples[2000:])
+
+	f.Calibrate(samples[2000:], expected[2000:])
+	calibrated := f.Classify(samples[2000:])
+	changed := false
+	for i := range raw {
+		changed = changed || calibrated[i] != raw[i]
+		if calibrated[i] < 0 || calibrated[i] > 1 {
+			t.Fatalf("Sample %d: calibrated probability %v is outside [0, 1]", i, calibrated[i])
+		}
+		for j := range raw {
+			if raw[i] < raw[j] && calibrated[i] > calibrated[j] {
+				t.Fatalf("Raw %v < %v, but calibrated %v > %v", raw[i], raw[j], calibrated[i], calibrated[j])
+			}
+		}
+	}
+	if !changed {
+		t.Errorf("Expected calibration to change the probabilities")
+	}
+}
@@ -0,0 +1,60 @@
+package trees
+
+import (
+	"math"
+)
+
+// Criterion is how the quality of a split is measured, lower scores being better.
+type Criterion int
+
+const (
+	// Misclassification scores a split by how many frames it classifies incorrectly.
+	Misclassification Criterion = iota
+	// Gini scores a split by the Gini impurity of each side.
+	Gini
+	// Entropy scores a split by the information entropy of each side.
+	Entropy
+)
+
+// nodeScore is the score for a node that is not split.
+func (c Criterion) nodeScore(misses float64, trueWeight float64, falseWeight float64) float64 {
+	if c == Misclassification {
+		return misses
+	}
+	return c.impurity(trueWeight, falseWeight)
+}
+
+// splitScore is the score for splitting a node into the below and above partitions.
+func (c Criterion) splitScore(misses float64,
+	trueBelow float64, falseBelow float64, trueAbove float64, falseAbove float64) float64 {
+	if c == Misclassification {
+		return misses
+	}
+	return c.impurity(trueBelow, falseBelow) + c.impurity(trueAbove, falseAbove)
+}
+
+// impurity of a partition, weighted by the size of that partition so that the sum
+// over both sides is proportional to the average impurity weighted by fraction of frames.
+func (c Criterion) impurity(trueWeight float64, falseWeight float64) float64 {
+	total := trueWeight + falseWeight
+	if total <= 0 {
+		return 0
+	}
+	p := trueWeight / total
+	switch c {
+	case Gini:
+		return total * 2 * p * (1 - p)
+	case Entropy:
+		return total * -(xLogX(p) + xLogX(1-p))
+	default:
+		return math.Min(trueWeight, falseWeight)
+	}
+}
+
+// xLogX calculates x * log2(x), taking 0 * log(0) as 0.
+func xLogX(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return x * math.Log2(x)
+}
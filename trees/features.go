@@ -0,0 +1,285 @@
+package trees
+
+import (
+	"fmt"
+
+	"github.com/padster/eego/signal"
+)
+
+// FeatureExtractor calculates the values the trees can split on, for a single frame.
+// Frames are always float64, including when training on int samples, so that features
+// combining samples (differences, sums, ...) can't wrap around for large-magnitude channels.
+type FeatureExtractor interface {
+	// FrameSize is the number of samples in each frame.
+	FrameSize() int
+	// FeatureCount is how many features are calculated for each frame.
+	FeatureCount() int
+	// Feature calculates a single feature, given the samples within a frame.
+	Feature(frame []float64, feature int) float64
+	// FeatureName is a readable label for what a feature index represents.
+	FeatureName(feature int) string
+}
+
+// frameFeatures are the default features: raw samples, differences, then summaries.
+type frameFeatures struct {
+	frameSize int
+}
+
+// NewFrameFeatures returns the default features for a frame of N samples:
+//   - [0, N): the N values in the frame
+//   - [N, 2N-1): the N - 1 differences between neighbouring values
+//   - 2N-1: the mean of the N values
+//   - 2N: the variance of the N values
+//   - 2N+1: the range of the N values, max - min
+//
+// Samples are used exactly as long as they are whole numbers within ±2^53, e.g. any int32
+// ADC reading. Differences and the range stay finite for magnitudes below ~1e307, and the
+// variance for magnitudes below ~1e150; beyond those they become +Inf rather than wrapping.
+func NewFrameFeatures(frameSize int) FeatureExtractor {
+	return frameFeatures{frameSize}
+}
+
+func (ff frameFeatures) FrameSize() int {
+	return ff.frameSize
+}
+
+func (ff frameFeatures) FeatureCount() int {
+	return 2*ff.frameSize + 2
+}
+
+func (ff frameFeatures) Feature(frame []float64, feature int) float64 {
+	if feature < ff.frameSize {
+		return frame[feature]
+	} else if (feature - ff.frameSize) < (ff.frameSize - 1) {
+		first := feature - ff.frameSize
+		// Subtracting in float64 rather than the original int type, so this can't overflow.
+		return frame[first+1] - frame[first]
+	} else if feature == 2*ff.frameSize-1 {
+		return mean(frame)
+	} else if feature == 2*ff.frameSize {
+		m := mean(frame)
+		sumSq := 0.0
+		for _, v := range frame {
+			sumSq += (v - m) * (v - m)
+		}
+		return sumSq / float64(ff.frameSize)
+	} else if feature == 2*ff.frameSize+1 {
+		min, max := frame[0], frame[0]
+		for _, v := range frame {
+			if v < min {
+				min = v
+			} else if v > max {
+				max = v
+			}
+		}
+		return max - min
+	} else {
+		panic(fmt.Sprintf("Unknown feature %d of %d", feature, ff.FeatureCount()))
+	}
+}
+
+func (ff frameFeatures) FeatureName(feature int) string {
+	if feature < ff.frameSize {
+		return fmt.Sprintf("sample[%d]", feature)
+	} else if (feature - ff.frameSize) < (ff.frameSize - 1) {
+		first := feature - ff.frameSize
+		return fmt.Sprintf("sample[%d]-sample[%d]", first+1, first)
+	} else if feature == 2*ff.frameSize-1 {
+		return "mean"
+	} else if feature == 2*ff.frameSize {
+		return "variance"
+	} else if feature == 2*ff.frameSize+1 {
+		return "range"
+	} else {
+		panic(fmt.Sprintf("Unknown feature %d of %d", feature, ff.FeatureCount()))
+	}
+}
+
+// multiResolutionFeatures averages the frame over progressively smaller windows.
+type multiResolutionFeatures struct {
+	frameSize int
+	levels    int
+}
+
+// NewMultiResolutionFeatures returns a coarse multiresolution summary of each frame,
+// made by averaging over the full frame, then halves, then quarters, and so on.
+// Level L splits the frame into 2^L equal windows, so the indices are laid out as:
+//   - [0, 1): mean of the full frame (level 0)
+//   - [1, 3): mean of each half (level 1)
+//   - [3, 7): mean of each quarter (level 2)
+//   - [2^L - 1, 2^(L+1) - 1): mean of each window at level L.
+//
+// The frame must have at least 2^(levels-1) samples, so that no window is empty.
+func NewMultiResolutionFeatures(frameSize int, levels int) FeatureExtractor {
+	if levels < 1 || frameSize < 1<<uint(levels-1) {
+		panic("Multiresolution features need at least one sample per window")
+	}
+	return multiResolutionFeatures{frameSize, levels}
+}
+
+func (mf multiResolutionFeatures) FrameSize() int {
+	return mf.frameSize
+}
+
+func (mf multiResolutionFeatures) FeatureCount() int {
+	return 1<<uint(mf.levels) - 1
+}
+
+func (mf multiResolutionFeatures) Feature(frame []float64, feature int) float64 {
+	lo, hi := mf.window(feature)
+	return mean(frame[lo:hi])
+}
+
+func (mf multiResolutionFeatures) FeatureName(feature int) string {
+	lo, hi := mf.window(feature)
+	return fmt.Sprintf("mean(sample[%d:%d])", lo, hi)
+}
+
+// window returns the [lo, hi) range of samples averaged for a feature.
+func (mf multiResolutionFeatures) window(feature int) (int, int) {
+	if feature < 0 || feature >= mf.FeatureCount() {
+		panic("Unknown multiresolution feature")
+	}
+	level := 0
+	for (1<<uint(level+1))-1 <= feature {
+		level++
+	}
+	windows := 1 << uint(level)
+	at := feature - (windows - 1)
+	return at * mf.frameSize / windows, (at + 1) * mf.frameSize / windows
+}
+
+// mean returns the average of the values.
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// lagFeatures are single samples at chosen distances before the end of the frame.
+type lagFeatures struct {
+	lags      []int
+	frameSize int
+}
+
+// NewLagFeatures returns one feature per lag, the sample that many samples before the last
+// (most recent) sample of the frame, so 0 is the last sample itself. The lags needn't be
+// contiguous, e.g. {1, 10, 50} covers a long context without a feature for every sample.
+// The frame size is the largest lag + 1.
+func NewLagFeatures(lags []int) FeatureExtractor {
+	if len(lags) == 0 {
+		panic("Lag features need at least one lag")
+	}
+	frameSize := 0
+	for _, lag := range lags {
+		if lag < 0 {
+			panic("Lags must not be negative")
+		}
+		if lag+1 > frameSize {
+			frameSize = lag + 1
+		}
+	}
+	return lagFeatures{append([]int(nil), lags...), frameSize}
+}
+
+func (lf lagFeatures) FrameSize() int {
+	return lf.frameSize
+}
+
+func (lf lagFeatures) FeatureCount() int {
+	return len(lf.lags)
+}
+
+func (lf lagFeatures) Feature(frame []float64, feature int) float64 {
+	return frame[len(frame)-1-lf.lags[feature]]
+}
+
+func (lf lagFeatures) FeatureName(feature int) string {
+	return fmt.Sprintf("sample[t-%d]", lf.lags[feature])
+}
+
+// multiChannelFeatures applies another extractor to each of several interleaved channels.
+type multiChannelFeatures struct {
+	base     FeatureExtractor
+	channels int
+}
+
+// NewMultiChannelFeatures applies the base features to each channel, for frames holding
+// several channels' samples one channel after another (every sample of the first channel,
+// then of the second, ...), see Forest.TrainMulti. Feature c*B + i is base feature i of channel c,
+// where B is base.FeatureCount(), so e.g. with the default features, feature c*B + j is
+// sample j of channel c.
+func NewMultiChannelFeatures(base FeatureExtractor, channels int) FeatureExtractor {
+	if channels < 1 {
+		panic("Multi-channel features need at least one channel")
+	}
+	return multiChannelFeatures{base, channels}
+}
+
+func (mf multiChannelFeatures) FrameSize() int {
+	return mf.base.FrameSize()
+}
+
+func (mf multiChannelFeatures) FeatureCount() int {
+	return mf.channels * mf.base.FeatureCount()
+}
+
+func (mf multiChannelFeatures) Feature(frame []float64, feature int) float64 {
+	baseCount, frameSize := mf.base.FeatureCount(), mf.base.FrameSize()
+	channel := feature / baseCount
+	return mf.base.Feature(frame[channel*frameSize:(channel+1)*frameSize], feature%baseCount)
+}
+
+func (mf multiChannelFeatures) FeatureName(feature int) string {
+	baseCount := mf.base.FeatureCount()
+	return fmt.Sprintf("ch%d:%s", feature/baseCount, mf.base.FeatureName(feature%baseCount))
+}
+
+// bandPowerFeatures adds the power in frequency bands to another extractor's features.
+type bandPowerFeatures struct {
+	base         FeatureExtractor
+	sampleRateHz float64
+	bands        [][2]float64
+}
+
+// NewBandPowerFeatures extends the base features with the power of the frame in each
+// [lowHz, highHz) band, e.g. alpha is {8, 13}. Indices [0, base.FeatureCount()) are the
+// base features, then one index per band, in order.
+// Frequency resolution is sampleRateHz / frameSize, so frames need to be long enough
+// that each band covers at least one frequency.
+func NewBandPowerFeatures(base FeatureExtractor, sampleRateHz float64, bands [][2]float64) FeatureExtractor {
+	for _, band := range bands {
+		if band[0] >= band[1] {
+			panic("Band power features need lowHz < highHz for every band")
+		}
+	}
+	return bandPowerFeatures{base, sampleRateHz, bands}
+}
+
+func (bf bandPowerFeatures) FrameSize() int {
+	return bf.base.FrameSize()
+}
+
+func (bf bandPowerFeatures) FeatureCount() int {
+	return bf.base.FeatureCount() + len(bf.bands)
+}
+
+func (bf bandPowerFeatures) Feature(frame []float64, feature int) float64 {
+	baseCount := bf.base.FeatureCount()
+	if feature < baseCount {
+		return bf.base.Feature(frame, feature)
+	}
+	band := bf.bands[feature-baseCount]
+	return signal.BandPower(frame, bf.sampleRateHz, [][2]float64{band})[0]
+}
+
+func (bf bandPowerFeatures) FeatureName(feature int) string {
+	baseCount := bf.base.FeatureCount()
+	if feature < baseCount {
+		return bf.base.FeatureName(feature)
+	}
+	band := bf.bands[feature-baseCount]
+	return fmt.Sprintf("power[%g-%gHz]", band[0], band[1])
+}
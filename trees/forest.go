@@ -3,8 +3,15 @@ package trees
 import (
 	"container/heap"
 	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/padster/eego/ml"
 	"github.com/padster/eego/util"
 )
 
@@ -25,6 +32,8 @@ An array of size D is created from each frame, by combining:
   - N values in the frame
   - N - 1 differences
   - 1 mean
+  - 1 variance
+  - 1 range (max - min)
   - ... other features? auto-detect?
 
 T trees are then created, each given access to look at a subset (~sqrt(D)) of indexes 
@@ -36,28 +45,92 @@ through the trees, and combining the results into an overall prediction.
 */
 
 // Remaining:
-//  - Algorithm to pick allowed sets
 //  - Create child nodes for leaf -> branch
 //  - test!
 
-// TODO - entropy instead of miscalculation? 
-// from here: http://www.saedsayad.com/decision_tree.htm
-
 // DOCS
 type Forest struct {
 	frameSize int
 	treeCount int
 	minMisclassified int
+	// How splits are scored.
+	criterion Criterion
+	// Nodes this deep are not split any further, -1 for no limit.
+	maxDepth int
+	// Splits must leave at least this many frames on each side.
+	minSamplesLeaf int
+	// Fraction by which a split must reduce the node's score to be made.
+	minImprovement float64
+	// If positive, splits are only tried at this many evenly spaced cutoffs, see SetHistogramBins.
+	histogramBins int
+
+	// Calculates the features to split on for each frame.
+	extractor FeatureExtractor
 
-	leafQueue nodeQueue
+	// Features each tree can split on, chosen from the candidates when training.
 	allowed [][]int
+	candidates []int
+	// Fraction of candidate features each tree may use, 0 for ~sqrt(D).
+	featureFraction float64
+	// Seeds the random bootstrap and feature selection for each tree.
+	seed int64
+	// Weight of each false (0) and true (1) frame, multiplied with any per-frame weights.
+	classWeight [2]float64
+	// Training frames start every stride samples.
+	stride int
+	// Whether to rescale each channel by its training mean and standard deviation, and those
+	// per-channel stats from the last Train, nil if not standardizing.
+	standardize bool
+	channelMean []float64
+	channelStd []float64
 
 	roots nodeQueue
 
 	// current training state
 	trainFrameCount int
-	trainSamples []int
+	trainSamples []float64
+	// For multi-channel training, each channel's samples separately, see trainChannelFrame.
+	trainChannels [][]float64
 	trainExpected []int
+	trainWeights []float64
+	// For each tree, which frames were in its bootstrap sample.
+	inBag [][]bool
+
+	// If positive, Train stops splitting once it has taken this long.
+	MaxTrainDuration time.Duration
+	// Details of the last call to Train.
+	stats TrainStats
+	// For each tree, the splits made during the last call to Train, in order.
+	splits [][]SplitInfo
+	// Whether to print details of each split as the trees are trained.
+	Verbose bool
+	// If set, called after each split made while training, with the number of nodes created,
+	// the leaves still queued to be split, and the total (rounded) training errors, all across
+	// every tree. Trees grow in parallel, but calls are made one at a time.
+	OnSplit func(nodesCreated, leavesRemaining, totalErrors int)
+	progress splitProgress
+	// Maps averaged tree probabilities to calibrated ones, nil until Calibrate is called.
+	calibration *ml.Logistic
+}
+
+// splitProgress is the forest-wide state passed to OnSplit.
+type splitProgress struct {
+	sync.Mutex
+	nodes int
+	queued int
+	errors float64
+}
+
+// TrainStats describes how the most recent call to Train went.
+type TrainStats struct {
+	// Whether splitting stopped early as MaxTrainDuration was exceeded.
+	DeadlineHit bool
+	// Number of nodes in the forest after training.
+	Nodes int
+	// Total frames scored while searching for splits, counting each feature tried separately.
+	FramesProcessed int64
+	// Wall time taken.
+	Duration time.Duration
 }
 
 // DOCS - Node of a tree within the forest.
@@ -68,14 +141,18 @@ type node struct {
 	inputs []int
 	// Classify as 1 (true) or 0 (false)
 	classifyAsTrue bool
-	// How many are misclassified at this point in the tree
-	misclassified int
+	// How many are misclassified at this point in the tree (weighted, if weights are given)
+	misclassified float64
+	// Fraction of the frames here which are true (weighted, if weights are given)
+	trueFraction float64
 	// Data specific to branches
 	branchData branchNode
 	// Whether it's a leaf or branch node.
 	isLeaf bool
 	// Which tree this comes from
 	originalRoot int
+	// How many branches are above this node, 0 for the root.
+	depth int
 }
 
 // DOCS
@@ -83,7 +160,7 @@ type branchNode struct {
 	// Index to decide on
 	decideFeature int
 	// Value to switch on, < decideCutoff go to lowerChild.
-	decideCutoff int
+	decideCutoff float64
 
 	// Next decision to make if this decision passes (branches)
 	lowerChild *node
@@ -93,94 +170,586 @@ type branchNode struct {
 
 // DOCS
 func NewForest(frameSize int, treeCount int, minMisclassified int) *Forest {
-	features := 2 * frameSize - 1
-	allowed := make([][]int, treeCount, treeCount)
-
-	// TODO - generate forbidden lists
-	if treeCount != 1 {
-		panic("Forest currently only supports single tree")
-	}
-	allowed[0] = make([]int, features, features)
-	for i := 0; i < features; i++ {
-		allowed[0][i] = i
+	if treeCount < 1 {
+		panic("Forest needs at least one tree")
 	}
+	extractor := NewFrameFeatures(frameSize)
 
 	f := Forest{
 		frameSize,
 		treeCount,
 		minMisclassified,
-		make(nodeQueue, treeCount),
-		allowed,
+		Misclassification,
+		-1, // maxDepth
+		1, // minSamplesLeaf
+		0.01, // minImprovement
+		0, // histogramBins
+		extractor,
+		make([][]int, treeCount, treeCount),
+		allFeatures(extractor.FeatureCount()),
+		0, // featureFraction
+		1, // seed
+		[2]float64{1.0, 1.0}, // classWeight
+		1, // stride
+		false, // standardize
+		nil,
+		nil,
 		make(nodeQueue, treeCount),
 		// These get filled in when training starts:
 		-1,
 		nil,
 		nil,
+		nil,
+		nil,
+		make([][]bool, treeCount, treeCount),
+		0, // no time limit
+		TrainStats{},
+		make([][]SplitInfo, treeCount, treeCount),
+		false, // Verbose
+		nil, // OnSplit
+		splitProgress{},
+		nil, // calibration
 	}
 	return &f
 }
 
+// SetFeatureExtractor changes which features the trees are able to split on.
+// The frame size of the forest becomes the frame size of the extractor.
+func (f *Forest) SetFeatureExtractor(extractor FeatureExtractor) {
+	f.extractor = extractor
+	f.frameSize = extractor.FrameSize()
+	f.candidates = allFeatures(extractor.FeatureCount())
+}
+
+// SetFeatureLags makes the trees split on the samples at the given lags before the
+// current sample, see NewLagFeatures. The frame size becomes the largest lag + 1.
+func (f *Forest) SetFeatureLags(lags []int) {
+	f.SetFeatureExtractor(NewLagFeatures(lags))
+}
+
+// SetAllowedFeatures restricts every tree to only split on the given feature indexes,
+// e.g. the top features from RankFeaturesByMI.
+func (f *Forest) SetAllowedFeatures(features []int) {
+	f.candidates = append([]int(nil), features...)
+}
+
+// SetCriterion sets how the quality of each candidate split is measured.
+func (f *Forest) SetCriterion(criterion Criterion) {
+	f.criterion = criterion
+}
+
+// SetMaxDepth limits how deep each tree can grow, where 0 only allows the root,
+// and a negative depth means there is no limit.
+func (f *Forest) SetMaxDepth(depth int) {
+	f.maxDepth = depth
+}
+
+// SetMinSamplesLeaf rejects any split that would leave fewer than this many frames in either child.
+func (f *Forest) SetMinSamplesLeaf(minSamples int) {
+	f.minSamplesLeaf = minSamples
+}
+
+// SetMinImprovement sets the fraction by which a split must improve on its node's score (by
+// the forest's criterion) to be made. The default of 0.01 requires splits to fix at least 1%
+// of the node; higher values give smaller trees, and 0 allows any improvement at all.
+func (f *Forest) SetMinImprovement(fraction float64) {
+	if fraction < 0 || fraction >= 1 {
+		panic("Minimum improvement must be in [0, 1)")
+	}
+	f.minImprovement = fraction
+}
+
+// SetHistogramBins speeds up training by only trying splits at the boundaries of this many
+// equal-width bins between the smallest and largest value of each feature at a node, rather
+// than between every pair of neighbouring values. This avoids sorting the node's frames for
+// every feature, at the cost of slightly worse splits. 0 (the default) tries every split.
+func (f *Forest) SetHistogramBins(bins int) {
+	if bins < 0 || bins == 1 {
+		panic("Histogram bins must be 0 for exact splits, or at least 2")
+	}
+	f.histogramBins = bins
+}
+
+// SetFeatureFraction sets what fraction of the candidate features each tree is randomly given.
+// Lower values decorrelate the trees. Zero (the default) uses ~sqrt(D) features, except for
+// a single tree which is given every feature.
+func (f *Forest) SetFeatureFraction(fraction float64) {
+	f.featureFraction = fraction
+}
+
+// SetSeed sets the seed used to pick each tree's frames and features, so training is reproducible.
+func (f *Forest) SetSeed(seed int64) {
+	f.seed = seed
+}
+
+// SetStride only trains on frames starting every stride samples, rather than at every sample.
+// Neighbouring frames overlap almost completely, so this cuts training time and memory by
+// about the stride with little loss. A stride equal to the frame size gives non-overlapping frames.
+func (f *Forest) SetStride(stride int) {
+	if stride < 1 {
+		panic("Stride must be at least 1")
+	}
+	f.stride = stride
+}
+
+// SetClassWeight scales how much false (classWeight[0]) and true (classWeight[1]) frames count
+// towards the split counts, on top of any per-frame weights. Events are rare, so without this
+// the trees can get a low error by classifying everything as false. See BalancedClassWeight.
+func (f *Forest) SetClassWeight(classWeight [2]float64) {
+	if classWeight[0] <= 0 || classWeight[1] <= 0 {
+		panic("Class weights must be positive")
+	}
+	f.classWeight = classWeight
+}
+
+// BalancedClassWeight weighs each class by the inverse of its frequency in expected, so that
+// both classes have the same total weight: n / (2 * count). A missing class is given weight 1.
+func BalancedClassWeight(expected []int) [2]float64 {
+	counts := [2]int{}
+	for _, v := range expected {
+		counts[v]++
+	}
+	weights := [2]float64{1.0, 1.0}
+	for c, count := range counts {
+		if count > 0 {
+			weights[c] = float64(len(expected)) / float64(2 * count)
+		}
+	}
+	return weights
+}
+
+// allFeatures lists every feature index.
+func allFeatures(features int) []int {
+	all := make([]int, features, features)
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// pickTreeData chooses the frames and features a tree is trained on. A single tree is
+// given every frame, otherwise each tree gets a bootstrap sample of the frames.
+// Each tree is then given a random subset of the candidate features, see SetFeatureFraction.
+func (f *Forest) pickTreeData(rng *rand.Rand) ([]int, []int) {
+	// Frames start every stride samples, see SetStride.
+	frameCount := 0
+	if f.trainFrameCount > 0 {
+		frameCount = (f.trainFrameCount - 1) / f.stride + 1
+	}
+	inputs := make([]int, frameCount, frameCount)
+	if f.treeCount == 1 {
+		for j := range inputs {
+			inputs[j] = j * f.stride
+		}
+		if f.featureFraction <= 0 {
+			return inputs, f.candidates
+		}
+	} else {
+		for j := range inputs {
+			inputs[j] = rng.Intn(frameCount) * f.stride
+		}
+	}
+
+	featureCount := int(math.Sqrt(float64(len(f.candidates))) + 0.5)
+	if f.featureFraction > 0 {
+		featureCount = int(f.featureFraction * float64(len(f.candidates)) + 0.5)
+	}
+	if featureCount < 1 {
+		featureCount = 1
+	} else if featureCount > len(f.candidates) {
+		featureCount = len(f.candidates)
+	}
+	allowed := make([]int, featureCount, featureCount)
+	for j, at := range rng.Perm(len(f.candidates))[:featureCount] {
+		allowed[j] = f.candidates[at]
+	}
+	sort.Ints(allowed)
+	return inputs, allowed
+}
+
 // DOCS
 func (f *Forest) Train(samples []int, expected []int) {
-	// Train-scoped variables:
-	f.trainSamples  = samples
-	f.trainExpected = expected
-	f.trainFrameCount = len(samples) - f.frameSize + 1
+	f.TrainFloatWeighted(asFloats(samples), expected, nil)
+}
 
-	// Initial state for root nodes of each tree:
-	trueCount := 0
-	for i := 0; i < f.trainFrameCount; i++ {
-		if expected[i + f.frameSize - 1] == 1 {
-			trueCount++
+// TrainWeighted trains the forest like Train, but with each frame's contribution to the
+// split counts scaled by a weight. Like expected, weights[i] applies to the frame ending
+// at sample i, so frames near the edge of an event window can be down-weighted.
+// A nil weights slice weighs every frame as 1.
+func (f *Forest) TrainWeighted(samples []int, expected []int, weights []float64) {
+	f.TrainFloatWeighted(asFloats(samples), expected, weights)
+}
+
+// TrainFloat trains the forest like Train, for samples which aren't whole numbers,
+// e.g. after filtering or normalization.
+func (f *Forest) TrainFloat(samples []float64, expected []int) {
+	f.TrainFloatWeighted(samples, expected, nil)
+}
+
+// TrainFloatWeighted trains the forest on float samples, with weighted frames like TrainWeighted.
+func (f *Forest) TrainFloatWeighted(samples []float64, expected []int, weights []float64) {
+	if f.channelCount() != 1 {
+		panic("Forest has multi-channel features, use TrainMulti")
+	}
+	f.train(samples, expected, weights)
+}
+
+// TrainMulti trains the forest on several channels recorded at the same time, e.g. every
+// EEG electrode, so splits can combine information across channels. The features of each
+// channel are those of the forest's extractor, so there are channels x FeatureCount() in
+// total, see NewMultiChannelFeatures for the layout. To restrict which of these are used,
+// call SetFeatureExtractor(NewMultiChannelFeatures(...)) then SetAllowedFeatures first.
+func (f *Forest) TrainMulti(channels [][]int, expected []int) {
+	if len(channels) == 0 {
+		panic("TrainMulti needs at least one channel")
+	}
+	if f.channelCount() != len(channels) {
+		base := f.extractor
+		if mc, ok := base.(multiChannelFeatures); ok {
+			base = mc.base
 		}
+		f.SetFeatureExtractor(NewMultiChannelFeatures(base, len(channels)))
 	}
-	moreTrue := trueCount > (f.trainFrameCount - trueCount)
-	misclassified := trueCount
-	if moreTrue {
-		misclassified = f.trainFrameCount - trueCount
+	f.train(interleave(channels), expected, nil)
+}
+
+// train is the shared implementation of the Train methods, for samples from
+// channelCount() channels interleaved together.
+func (f *Forest) train(samples []float64, expected []int, weights []float64) {
+	if weights != nil && len(weights) != len(expected) {
+		panic("Weights must be the same size as the expected values")
 	}
-	// fmt.Printf("moreTrue = %v, misclassified = %v\n", moreTrue, misclassified)
+	startTime := time.Now()
+	f.stats = TrainStats{}
+	f.calibration = nil
+	for i := range f.splits {
+		f.splits[i] = nil
+	}
+
+	// Train-scoped variables:
+	f.fitChannelStats(samples)
+	samples = f.standardized(samples)
+	f.trainSamples  = samples
+	f.trainChannels = deinterleave(samples, f.channelCount())
+	f.trainExpected = expected
+	f.trainWeights = weights
+	f.trainFrameCount = len(samples) / f.channelCount() - f.frameSize + 1
 
-	// Create each root node separately:
+	// Create each root node separately, picking data up front so it doesn't depend on scheduling:
+	rng := rand.New(rand.NewSource(f.seed))
 	for i := 0; i < f.treeCount; i++ {
 		// fmt.Printf("Creating node %d\n", i)
+		inputs, allowed := f.pickTreeData(rng)
+		f.allowed[i] = allowed
+		f.inBag[i] = make([]bool, f.trainFrameCount, f.trainFrameCount)
+		for _, frame := range inputs {
+			f.inBag[i][frame] = true
+		}
+
+		// Initial state for the root, which classifies everything the same:
+		trueWeight, totalWeight := 0.0, 0.0
+		for _, frame := range inputs {
+			totalWeight += f.frameWeight(frame)
+			if expected[frame + f.frameSize - 1] == 1 {
+				trueWeight += f.frameWeight(frame)
+			}
+		}
+		moreTrue := trueWeight > (totalWeight - trueWeight)
+		misclassified := trueWeight
+		if moreTrue {
+			misclassified = totalWeight - trueWeight
+		}
+
 		f.roots[i] = &node{
 			nil,
-			make([]int, f.trainFrameCount, f.trainFrameCount),
+			inputs,
 			moreTrue, // classifyAsTrue
 			misclassified,
+			0, // trueFraction, set below
 			branchNode{
 				-1, -1,
 				nil, nil,
 			},
 			true, // isLeaf
 			i, // originalRoot
+			0, // depth
 		}
-		f.leafQueue[i] = f.roots[i]
+		f.roots[i].trueFraction = f.roots[i].trueProbability(f)
+	}
 
-		// Pre-fill inputs and initial best split point.
-		for j := 0; j < f.trainFrameCount; j++ {
-			f.leafQueue[i].inputs[j] = j
-		}
-		f.leafQueue[i].precalcBestSplit(f)
+	f.startProgress()
+
+	// Grow each tree in its own goroutine, with at most GOMAXPROCS running at once:
+	deadlineHit := make([]bool, f.treeCount, f.treeCount)
+	running := make(chan bool, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range f.roots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			running <- true
+			defer func() { <-running }()
+			deadlineHit[i] = f.growTree(f.roots[i], startTime)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, hit := range deadlineHit {
+		f.stats.DeadlineHit = f.stats.DeadlineHit || hit
 	}
+	f.stats.Nodes = f.DecisionNodes()
+	f.stats.Duration = time.Since(startTime)
+}
 
-	// Split the nodes until we're close enough:
-	// fmt.Printf("Initting heap...\n")
-	heap.Init(&f.leafQueue)
-	for len(f.leafQueue) > 0 {
-		nextLeaf := heap.Pop(&f.leafQueue).(*node)
+// growTree splits the nodes of a single tree until we're close enough.
+// Returns whether it stopped early due to running out of time.
+func (f *Forest) growTree(root *node, startTime time.Time) bool {
+	leafQueue := make(nodeQueue, 0)
+
+	// Initial best split point, only queued if one exists.
+	if f.canSplit(root) {
+		root.precalcBestSplit(f)
+	}
+	if root.branchData.decideFeature != -1 {
+		heap.Push(&leafQueue, root)
+		f.trackQueued(1)
+	}
+	return f.growQueue(&leafQueue, startTime)
+}
+
+// growQueue repeatedly converts the best leaf in the queue into a branch, until no
+// leaves are worth splitting. Returns whether it stopped early due to running out of time.
+func (f *Forest) growQueue(leafQueue *nodeQueue, startTime time.Time) bool {
+	// Whatever is left over when we stop is no longer waiting to be split.
+	defer func() { f.trackQueued(-len(*leafQueue)) }()
+	for len(*leafQueue) > 0 {
+		nextLeaf := heap.Pop(leafQueue).(*node)
+		f.trackQueued(-1)
 		// fmt.Printf("Splitting node which misclassifies %d\n", nextLeaf.misclassified)
 		if nextLeaf.branchData.decideFeature == -1 {
 			// Nothing left to split, we've done as much as possible.
 			break
 		}
-		if nextLeaf.misclassified < f.minMisclassified {
+		if nextLeaf.misclassified < float64(f.minMisclassified) {
 			// Only rounding error left
 			break
 		}
-		nextLeaf.convertToBranch(f)
+		if f.MaxTrainDuration > 0 && time.Since(startTime) > f.MaxTrainDuration {
+			// Out of time, keep the tree as it is so far.
+			return true
+		}
+		nextLeaf.convertToBranch(f, leafQueue)
+	}
+	return false
+}
+
+// startProgress resets the OnSplit counts to match the trees as they are before growing.
+func (f *Forest) startProgress() {
+	if f.OnSplit == nil {
+		return
+	}
+	f.progress.Lock()
+	defer f.progress.Unlock()
+	f.progress.nodes, f.progress.queued, f.progress.errors = f.DecisionNodes(), 0, 0
+	for _, root := range f.roots {
+		f.progress.errors += root.totalErrors()
+	}
+}
+
+// trackQueued records leaves being added to or removed from a tree's queue.
+func (f *Forest) trackQueued(delta int) {
+	if f.OnSplit == nil {
+		return
+	}
+	f.progress.Lock()
+	f.progress.queued += delta
+	f.progress.Unlock()
+}
+
+// reportSplit updates the counts for a node that has just become a branch, then calls OnSplit.
+func (f *Forest) reportSplit(n *node) {
+	if f.OnSplit == nil {
+		return
+	}
+	f.progress.Lock()
+	defer f.progress.Unlock()
+	f.progress.nodes += 2
+	f.progress.errors -= n.splitFix()
+	f.OnSplit(f.progress.nodes, f.progress.queued, int(math.Round(f.progress.errors)))
+}
+
+// Reset drops the trained trees and the references to the training data, keeping all the
+// configuration, so the same forest can be trained again on another dataset (e.g. the next
+// cross-validation fold) without allocating a new one, and without keeping the old trees
+// alive in the meantime. The forest must be trained again before it can classify.
+func (f *Forest) Reset() {
+	for i := range f.roots {
+		f.roots[i] = nil
+		f.allowed[i] = nil
+		f.inBag[i] = nil
+		f.splits[i] = nil
+	}
+	f.trainFrameCount = -1
+	f.trainSamples = nil
+	f.trainChannels = nil
+	f.channelMean, f.channelStd = nil, nil
+	f.trainExpected = nil
+	f.trainWeights = nil
+	f.stats = TrainStats{}
+	f.calibration = nil
+}
+
+// frameWeight returns how much a training frame counts towards the split counts.
+func (f *Forest) frameWeight(frame int) float64 {
+	weight := f.classWeight[f.trainExpected[frame + f.frameSize - 1]]
+	if f.trainWeights == nil {
+		return weight
 	}
+	return weight * f.trainWeights[frame + f.frameSize - 1]
+}
+
+// TrainStats returns details about the most recent call to Train.
+func (f *Forest) TrainStats() TrainStats {
+	return f.stats
+}
+
+// Classify returns the probability of each sample being true, averaged across all trees.
+// Each tree's probability is the fraction of its training frames in the leaf which were true.
+// Each sample is classified using the frame of the last N samples up to and including it,
+// with the first N-1 samples zero-padded at the start.
+func (f *Forest) Classify(samples []int) []float64 {
+	return f.ClassifyFloat(asFloats(samples))
+}
+
+// ClassifyFloat classifies each sample like Classify, for samples which aren't whole numbers.
+func (f *Forest) ClassifyFloat(samples []float64) []float64 {
+	if f.channelCount() != 1 {
+		panic("Forest has multi-channel features, use ClassifyMulti")
+	}
+	return f.classify(samples)
+}
+
+// ClassifyMulti classifies each sample like Classify, for a forest trained with TrainMulti
+// on the same number of channels.
+func (f *Forest) ClassifyMulti(channels [][]int) []float64 {
+	if f.channelCount() != len(channels) {
+		panic("ClassifyMulti needs the same number of channels as the forest was trained on")
+	}
+	return f.classify(interleave(channels))
+}
+
+// classify is the shared implementation of the Classify methods, for samples from
+// channelCount() channels interleaved together.
+func (f *Forest) classify(samples []float64) []float64 {
+	samples = f.standardized(samples)
+	result := make([]float64, len(samples) / f.channelCount(), len(samples) / f.channelCount())
+	f.eachFrame(samples, func(i int, frame []float64) {
+		result[i] = f.probability(frame)
+	})
+	return result
+}
+
+// probability is the forest's (calibrated, if set) probability of a single frame being true.
+func (f *Forest) probability(frame []float64) float64 {
+	total := 0.0
+	for _, root := range f.roots {
+		total += root.classifyFrame(f, frame).trueFraction
+	}
+	result := total / float64(len(f.roots))
+	if f.calibration != nil {
+		result = f.calibration.Predict(result)
+	}
+	return result
+}
+
+// ClassifyPerTree classifies each sample like Classify, but rather than averaging across the
+// trees, returns each tree's own probabilities: result[t][i] is tree t's for sample i.
+// The spread across trees shows how much they disagree, i.e. how decorrelated they are.
+func (f *Forest) ClassifyPerTree(samples []int) [][]float64 {
+	if f.channelCount() != 1 {
+		panic("Forest has multi-channel features, ClassifyPerTree only supports one channel")
+	}
+	result := make([][]float64, len(f.roots), len(f.roots))
+	for t := range result {
+		result[t] = make([]float64, len(samples), len(samples))
+	}
+	f.eachFrame(f.standardized(asFloats(samples)), func(i int, frame []float64) {
+		for t, root := range f.roots {
+			result[t][i] = root.classifyFrame(f, frame).trueFraction
+		}
+	})
+	return result
+}
+
+// eachFrame calls visit with the frame ending at each sample, for samples from channelCount()
+// channels interleaved together, zero-padding the first frameSize - 1. Frames hold each
+// channel's samples in turn, as multiChannelFeatures expects. The frame is reused between
+// calls, so must not be kept.
+func (f *Forest) eachFrame(samples []float64, visit func(i int, frame []float64)) {
+	channels := f.channelCount()
+	frame := make([]float64, f.frameSize * channels, f.frameSize * channels)
+	for i := 0; i < len(samples) / channels; i++ {
+		for j := 0; j < f.frameSize; j++ {
+			at := i - f.frameSize + 1 + j
+			for c := 0; c < channels; c++ {
+				if at < 0 {
+					frame[c * f.frameSize + j] = 0
+				} else {
+					frame[c * f.frameSize + j] = samples[at * channels + c]
+				}
+			}
+		}
+		visit(i, frame)
+	}
+}
+
+// classifyFrame walks down the tree from this node to find which leaf a frame ends up in.
+func (n *node) classifyFrame(f *Forest, frame []float64) *node {
+	at := n
+	for !at.isLeaf {
+		score := f.extractor.Feature(frame, at.branchData.decideFeature)
+		if score < at.branchData.decideCutoff {
+			at = at.branchData.lowerChild
+		} else {
+			at = at.branchData.highEqChild
+		}
+	}
+	return at
+}
+
+// OOBError estimates the generalization error using out-of-bag frames: each training frame
+// is classified using only the trees whose bootstrap sample did not include it. Returns the
+// fraction misclassified, out of all frames which were out-of-bag for at least one tree,
+// or NaN if there are no such frames (e.g. for a single tree, which uses every frame).
+func (f *Forest) OOBError() float64 {
+	oobFrames, wrong := 0, 0
+	for frame := 0; frame < f.trainFrameCount; frame += f.stride {
+		trees, total := 0, 0.0
+		samples := f.trainFrame(frame)
+		for t, root := range f.roots {
+			if f.inBag[t][frame] {
+				continue
+			}
+			trees++
+			if root.classifyFrame(f, samples).classifyAsTrue {
+				total += 1.0
+			}
+		}
+		if trees == 0 {
+			continue
+		}
+
+		oobFrames++
+		predictTrue := total / float64(trees) >= 0.5
+		if predictTrue != (f.trainExpected[frame + f.frameSize - 1] == 1) {
+			wrong++
+		}
+	}
+
+	if oobFrames == 0 {
+		return math.NaN()
+	}
+	return float64(wrong) / float64(oobFrames)
 }
 
 // DOCS - Number of nodes in the entire forest
@@ -194,21 +763,57 @@ func (f *Forest) DecisionNodes() int {
 
 // DOCS - average miscalculations across all roots
 func (f *Forest) AverageErrors() float64 {
-	errors := 0
+	errors := 0.0
 	for _, n := range f.roots {
 		errors += n.totalErrors()
 	}
-	return float64(errors) / float64(len(f.roots))
+	return errors / float64(len(f.roots))
+}
+
+// FeatureImportances returns how much each feature reduces misclassification across
+// every branch of every tree, normalized so the importances sum to 1.
+func (f *Forest) FeatureImportances() []float64 {
+	importances := make([]float64, f.extractor.FeatureCount())
+	for _, root := range f.roots {
+		root.addImportances(importances)
+	}
+
+	total := 0.0
+	for _, v := range importances {
+		total += v
+	}
+	if total > 0 {
+		for i := range importances {
+			importances[i] /= total
+		}
+	}
+	return importances
+}
+
+// addImportances accumulates the misclassification fixed by each branch in this subtree.
+func (n *node) addImportances(importances []float64) {
+	if n.isLeaf {
+		return
+	}
+	importances[n.branchData.decideFeature] += n.splitFix()
+	n.branchData.lowerChild.addImportances(importances)
+	n.branchData.highEqChild.addImportances(importances)
+}
+
+// splitFix is how much misclassification is reduced by splitting this node into its children.
+func (n *node) splitFix() float64 {
+	return n.misclassified - (
+		n.branchData.lowerChild.misclassified +
+		n.branchData.highEqChild.misclassified)
 }
 
 // DOCS - fill in the branch node data with the best split decision
 func (n *node) precalcBestSplit(f *Forest) {
 	// fmt.Printf("!!!Presplitting node %v\n", n)
-	// Find all remaining features that we can decide on:
-	allowed := map[int]bool{}
-	for _, v := range f.allowed[n.originalRoot] {
-		allowed[v] = true
-	}
+	// Find all remaining features that we can decide on, in order so that ties between
+	// equally good splits always go to the lowest feature index:
+	allowed := append([]int{}, f.allowed[n.originalRoot]...)
+	sort.Ints(allowed)
 	// PICK: remove allowed nodes?
 	// for at := n.parent; at != nil; at = at.parent {
 		// fmt.Printf("Used %d at parent %v\n", at.branchData.decideFeature, at)
@@ -228,12 +833,17 @@ func (n *node) precalcBestSplit(f *Forest) {
 	// fmt.Printf("}\n")
 
 	// Find the best of those, which is also a big enough improvement.
-	upperBar := int(float64(n.misclassified) * 0.99) // need to at least be fix 1%
+	trueWeight, falseWeight := n.classWeights(f)
+	upperBar := f.criterion.nodeScore(n.misclassified, trueWeight, falseWeight) * (1 - f.minImprovement)
+	if f.criterion == Misclassification {
+		upperBar = math.Floor(upperBar)
+	}
 
-	bestSplit := splitDetails{-1, -1, false, upperBar, -1, -1}
-	for splitFeature := range allowed {
+	// Only strictly better splits replace the best, so the first found wins ties.
+	bestSplit := splitDetails{-1, -1, false, -1, -1, -1, upperBar}
+	for _, splitFeature := range allowed {
 		nextSplit := n.splitReduction(f, splitFeature)
-		if nextSplit.misses < bestSplit.misses {
+		if nextSplit.score < bestSplit.score {
 			bestSplit = nextSplit
 		}
 	}
@@ -247,96 +857,77 @@ func (n *node) precalcBestSplit(f *Forest) {
 
 // HACK
 type splitDetails struct {
-	splitValue int
+	splitValue float64
 	splitFeature int
 	trueBelow bool
-	misses int
-	missesBelow int
-	missesAbove int
+	misses float64
+	missesBelow float64
+	missesAbove float64
+	// How good the split is by the forest's criterion, lower is better.
+	score float64
 }
 
 // DOCS - misclassified improvement given a feature to split
 func (n *node) splitReduction(f *Forest, feature int) splitDetails {
+	if f.histogramBins > 0 {
+		return n.binnedSplitReduction(f, feature)
+	}
 	// fmt.Printf("Trying to split %v on feature %d\n", n, feature)
 	nFrames := len(n.inputs)
 
 	// Sort, find best split, then return new misclassification details.
-	trueBelow, trueAbove := 0, n.misclassified
-	falseBelow, falseAbove := 0, nFrames - n.misclassified
-	if n.classifyAsTrue {
-		trueAbove = nFrames - n.misclassified
-		falseAbove = n.misclassified
-	}
+	trueBelow, falseBelow := 0.0, 0.0
+	trueAbove, falseAbove := n.classWeights(f)
 	// fmt.Printf("TB/TA/FB/FA = %d/%d/%d/%d\n", 
 		// trueBelow, trueAbove, falseBelow, falseAbove)
 
 	// currentWrong := n.misclassified
-	dsii := util.DualSortII {
-		make([]int, nFrames, nFrames),
-		make([]int, nFrames, nFrames),
-	}
-
-	// Find the value for each frame for the given feature:
+	// Find the value for each frame for the given feature, and the order they sort in:
+	atomic.AddInt64(&f.stats.FramesProcessed, int64(nFrames))
+	scores := make([]float64, nFrames, nFrames)
 	for i, frame := range n.inputs {
-		dsii.V1[i] = scoreForFrameAndFeature(f, frame, feature)
-		dsii.V2[i] = frame
+		scores[i] = scoreForFrameAndFeature(f, frame, feature)
 	}
-	sort.Sort(dsii)
-	// fmt.Printf("scores = %v\n", dsii.V1)
-	// fmt.Printf("indexs = %v\n", dsii.V2)
+	order := util.Argsort(scores)
 
-	// HACK - remove
-	tmp := make([]int, nFrames, nFrames)
-	for i := 0; i < nFrames; i++ {
-		tmp[i] = f.trainExpected[dsii.V2[i] + f.frameSize - 1]
+	// Cutoffs are tried in increasing order, so ties go to the lowest.
+	bestSplit := splitDetails{
+		-1, -1, false, n.misclassified, -1, -1,
+		f.criterion.nodeScore(n.misclassified, trueAbove, falseAbove),
 	}
-	// fmt.Printf("output = %v\n", tmp)
-
-
-	bestSplit := splitDetails{-1, -1, false, n.misclassified, -1, -1}
 
 	for splitBefore := 0; splitBefore < nFrames; splitBefore++ {
 		// Splitting on the same value isn't allowed, numbers are wrong.
 		considerSplit := true
-		thisSplit := dsii.V1[splitBefore]
+		thisSplit := scores[order[splitBefore]]
 		if splitBefore > 0 {
-			lastSplit := dsii.V1[splitBefore - 1]
+			lastSplit := scores[order[splitBefore - 1]]
 			if thisSplit == lastSplit {
 				// fmt.Printf("Skipping %d\n", thisSplit)
-				considerSplit = true
+				considerSplit = false
 			}
 		}
+		// Both sides need enough frames to be worth splitting.
+		if splitBefore < f.minSamplesLeaf || nFrames - splitBefore < f.minSamplesLeaf {
+			considerSplit = false
+		}
 
 		// Derive miscalculations based on splitting here
 		if considerSplit {
-			missAsFalseBelow := trueBelow + falseAbove
-			missAsTrueBelow := falseBelow + trueAbove
-			// fmt.Printf("Trying split at %d, missTB, missFB = %d, %d\n", 
-				// thisSplit, missAsTrueBelow, missAsFalseBelow)
-			if missAsTrueBelow < missAsFalseBelow {
-				if missAsTrueBelow < bestSplit.misses {
-					bestSplit = splitDetails{
-						thisSplit, feature, true, 
-						missAsTrueBelow, falseBelow, trueAbove,
-					}
-				}
-			} else {
-				if missAsFalseBelow < bestSplit.misses {
-					bestSplit = splitDetails{
-						thisSplit, feature, false, 
-						missAsFalseBelow, trueBelow, falseAbove,
-					}
-				}
+			candidate := scoreSplit(f, feature, thisSplit, trueBelow, falseBelow, trueAbove, falseAbove)
+			if candidate.score < bestSplit.score {
+				bestSplit = candidate
 			}
 		}
 
-		frame := dsii.V2[splitBefore] + f.frameSize - 1
-		if f.trainExpected[frame] == 1 {
-			trueBelow++
-			trueAbove--
+		frame := n.inputs[order[splitBefore]]
+		weight := f.frameWeight(frame)
+		if f.trainExpected[frame + f.frameSize - 1] == 1 {
+			trueBelow += weight
+			trueAbove -= weight
 		} else {
-			falseBelow++
-			falseAbove--
+			falseBelow += weight
+			falseAbove -= weight
 		}
 	}
 
@@ -345,25 +936,47 @@ func (n *node) splitReduction(f *Forest, feature int) splitDetails {
 	return bestSplit
 }
 
+// scoreSplit gives the details of splitting at a cutoff, given the weight of each class on
+// either side, classifying each side as whichever misclassifies less.
+func scoreSplit(f *Forest, feature int, cutoff float64,
+	trueBelow float64, falseBelow float64, trueAbove float64, falseAbove float64) splitDetails {
+	missAsFalseBelow := trueBelow + falseAbove
+	missAsTrueBelow := falseBelow + trueAbove
+	candidate := splitDetails{
+		cutoff, feature, false,
+		missAsFalseBelow, trueBelow, falseAbove, 0,
+	}
+	if missAsTrueBelow < missAsFalseBelow {
+		candidate = splitDetails{
+			cutoff, feature, true,
+			missAsTrueBelow, falseBelow, trueAbove, 0,
+		}
+	}
+	candidate.score = f.criterion.splitScore(candidate.misses,
+		trueBelow, falseBelow, trueAbove, falseAbove)
+	return candidate
+}
+
 // DOCS - split a node on a given feature
 func (n *node) presplitOn(f *Forest, split splitDetails) {
-	fmt.Printf("Splitting node with %d mis, by: %v\n", n.misclassified, split)
+	if f.Verbose {
+		fmt.Printf("Splitting node with %v mis, by: %v\n", n.misclassified, split)
+	}
 
 	lo, hi := 0, len(n.inputs) - 1
 	for lo < hi {
 		for ; lo < hi; lo++ {
 			score := scoreForFrameAndFeature(f, n.inputs[lo], split.splitFeature)
 			isBelow := score < split.splitValue
-			// In the wrong place if isBelow == true && trueBelow == false, or
-			// isBelow == false && trueBelow == true
-			if isBelow != split.trueBelow {
+			// Frames below the cutoff belong at the start, for the lower child.
+			if !isBelow {
 				break
 			}
 		}
 		for ; lo < hi; hi-- {
 			score := scoreForFrameAndFeature(f, n.inputs[hi], split.splitFeature)
 			isBelow := score < split.splitValue
-			if isBelow == split.trueBelow {
+			if isBelow {
 				break
 			}
 		}
@@ -376,7 +989,7 @@ func (n *node) presplitOn(f *Forest, split splitDetails) {
 	for ; lo < len(n.inputs); lo++ {
 		score := scoreForFrameAndFeature(f, n.inputs[lo], split.splitFeature)
 		isBelow := score < split.splitValue
-		if isBelow != split.trueBelow {
+		if !isBelow {
 			break
 		}
 		// fmt.Printf("Bumping slice point to %d\n", lo)
@@ -392,24 +1005,30 @@ func (n *node) presplitOn(f *Forest, split splitDetails) {
 		n.inputs[:slicePoint],
 		split.trueBelow,
 		split.missesBelow,
+		0, // trueFraction, set below
 		branchNode{-1, -1, nil, nil},
 		true, // isLeaf,
 		n.originalRoot,
+		n.depth + 1,
 	}
 	n.branchData.highEqChild = &node{
 		n,
 		n.inputs[slicePoint:],
 		!split.trueBelow,
 		split.missesAbove,
+		0, // trueFraction, set below
 		branchNode{-1, -1, nil, nil},
 		true, // isLeaf,
 		n.originalRoot,
+		n.depth + 1,
 	}
+	n.branchData.lowerChild.trueFraction = n.branchData.lowerChild.trueProbability(f)
+	n.branchData.highEqChild.trueFraction = n.branchData.highEqChild.trueProbability(f)
 	// fmt.Printf("Created two children:\n\t<\t%v\n\t>=\t%v\n", n.branchData.lowerChild, n.branchData.highEqChild)
 }
 
 // DOCS - number of misclassified frames
-func (n *node) totalErrors() int {
+func (n *node) totalErrors() float64 {
 	if n.isLeaf {
 		return n.misclassified
 	} else {
@@ -426,38 +1045,153 @@ func (n *node) subtreeSize() int {
 	return count
 }
 
+// classWeights returns the total weight of the true and false frames at this node.
+func (n *node) classWeights(f *Forest) (float64, float64) {
+	total := f.inputsWeight(n.inputs)
+	if n.classifyAsTrue {
+		return total - n.misclassified, n.misclassified
+	}
+	return n.misclassified, total - n.misclassified
+}
+
+// asFloats converts whole number samples to floats.
+func asFloats(samples []int) []float64 {
+	result := make([]float64, len(samples), len(samples))
+	for i, v := range samples {
+		result[i] = float64(v)
+	}
+	return result
+}
+
+// inputsWeight is the total weight of the given training frames.
+func (f *Forest) inputsWeight(frames []int) float64 {
+	if f.trainWeights == nil && f.classWeight == [2]float64{1.0, 1.0} {
+		return float64(len(frames))
+	}
+	total := 0.0
+	for _, frame := range frames {
+		total += f.frameWeight(frame)
+	}
+	return total
+}
+
 // DOCS - pull out a feature for a given frame
-func scoreForFrameAndFeature(f *Forest, frame int, feature int) int {
-	// PICK - apply another mapping, i.e. use frame + MAP[feature] not frame + feature?
-	if feature < f.frameSize {
-		return f.trainSamples[frame + feature]
-	} else if (feature - f.frameSize) < (f.frameSize - 1) {
-		first := frame + (feature - f.frameSize)
-		return f.trainSamples[first + 1] - f.trainSamples[first]
-	} else {
-		panic("TODO - support more features?")
+func scoreForFrameAndFeature(f *Forest, frame int, feature int) float64 {
+	if mc, ok := f.extractor.(multiChannelFeatures); ok {
+		// Only look at the one channel, rather than copying every channel's samples.
+		baseCount := mc.base.FeatureCount()
+		return mc.base.Feature(f.trainChannelFrame(feature / baseCount, frame), feature % baseCount)
+	}
+	return f.extractor.Feature(f.trainFrame(frame), feature)
+}
+
+// trainFrame returns the training samples for a frame, from every channel, laid out as
+// for eachFrame. With more than one channel, this is a copy.
+func (f *Forest) trainFrame(frame int) []float64 {
+	if f.trainChannels == nil {
+		return f.trainSamples[frame : frame + f.frameSize]
 	}
+	result := make([]float64, 0, f.frameSize * len(f.trainChannels))
+	for c := range f.trainChannels {
+		result = append(result, f.trainChannelFrame(c, frame)...)
+	}
+	return result
+}
+
+// trainChannelFrame returns one channel's training samples for a frame, for multi-channel training.
+func (f *Forest) trainChannelFrame(channel int, frame int) []float64 {
+	return f.trainChannels[channel][frame : frame + f.frameSize]
+}
+
+// channelCount is how many channels each frame has samples from, see TrainMulti.
+func (f *Forest) channelCount() int {
+	if mc, ok := f.extractor.(multiChannelFeatures); ok {
+		return mc.channels
+	}
+	return 1
+}
+
+// deinterleave splits samples from several channels interleaved together back into a slice
+// per channel, or returns nil for a single channel, which needs no splitting.
+func deinterleave(samples []float64, channels int) [][]float64 {
+	if channels == 1 {
+		return nil
+	}
+	result := make([][]float64, channels, channels)
+	for c := range result {
+		result[c] = make([]float64, len(samples) / channels, len(samples) / channels)
+	}
+	for i, v := range samples {
+		result[i % channels][i / channels] = v
+	}
+	return result
+}
+
+// interleave joins same-length channels into one slice, with all channels' values for
+// the first sample, then all for the second, and so on.
+func interleave(channels [][]int) []float64 {
+	n := len(channels[0])
+	result := make([]float64, n * len(channels), n * len(channels))
+	for c, samples := range channels {
+		if len(samples) != n {
+			panic("All channels must be the same length")
+		}
+		for i, v := range samples {
+			result[i * len(channels) + c] = float64(v)
+		}
+	}
+	return result
 }
 
 
 // DOCS - this leaf node is being converted into a decision one instead.
-func (n *node) convertToBranch(f *Forest) {
-	// TODO - don't convert if it makes things worse.
+func (n *node) convertToBranch(f *Forest, leafQueue *nodeQueue) {
+	lowerChild, upperChild := n.branchData.lowerChild, n.branchData.highEqChild
+	if !n.splitImproves(f) {
+		// Splitting doesn't make things any better, so stay as a leaf.
+		n.branchData = branchNode{-1, -1, nil, nil}
+		return
+	}
+
 	n.isLeaf = false
+	f.splits[n.originalRoot] = append(f.splits[n.originalRoot], n.splitInfo(f))
 	// fmt.Printf("Converting to branch, pre-calc split both children\n")
-	lowerChild, upperChild := n.branchData.lowerChild, n.branchData.highEqChild
-	if lowerChild.misclassified > 0 {
+	if lowerChild.misclassified > 0 && f.canSplit(lowerChild) {
 		lowerChild.precalcBestSplit(f)
 		if lowerChild.branchData.decideFeature != -1 {
-			heap.Push(&f.leafQueue, lowerChild)
+			heap.Push(leafQueue, lowerChild)
+			f.trackQueued(1)
 		}
 	}
-	if upperChild.misclassified > 0 {
+	if upperChild.misclassified > 0 && f.canSplit(upperChild) {
 		upperChild.precalcBestSplit(f)
 		if upperChild.branchData.decideFeature != -1 {
-			heap.Push(&f.leafQueue, upperChild)
+			heap.Push(leafQueue, upperChild)
+			f.trackQueued(1)
 		}	
 	}
+	f.reportSplit(n)
+}
+
+// splitImproves returns whether the presplit children score better than this node by the
+// forest's criterion. Gini and entropy can improve without fixing any misclassifications,
+// e.g. by splitting off a pure part of the node, which enables better splits further down.
+func (n *node) splitImproves(f *Forest) bool {
+	lowerChild, upperChild := n.branchData.lowerChild, n.branchData.highEqChild
+	misses := lowerChild.misclassified + upperChild.misclassified
+	if f.criterion == Misclassification {
+		return misses < n.misclassified
+	}
+	trueWeight, falseWeight := n.classWeights(f)
+	trueBelow, falseBelow := lowerChild.classWeights(f)
+	trueAbove, falseAbove := upperChild.classWeights(f)
+	return f.criterion.splitScore(misses, trueBelow, falseBelow, trueAbove, falseAbove) <
+		f.criterion.nodeScore(n.misclassified, trueWeight, falseWeight)
+}
+
+// canSplit returns whether a node is shallow enough to be split further.
+func (f *Forest) canSplit(n *node) bool {
+	return f.maxDepth < 0 || n.depth < f.maxDepth
 }
 
 // Priority queue for leaf nodes:
@@ -485,15 +1219,7 @@ func (pq *nodeQueue) Len() int {
 
 // post: true iff is i less than j
 func (pq *nodeQueue) Less(i, j int) bool {
-    I := (*pq)[i]
-    J := (*pq)[j]
-    iFix := I.misclassified - (
-    	I.branchData.lowerChild.misclassified +
-    	I.branchData.highEqChild.misclassified)
-    jFix := J.misclassified - (
-    	J.branchData.lowerChild.misclassified +
-    	J.branchData.highEqChild.misclassified)
-    return iFix > jFix
+    return (*pq)[i].splitFix() > (*pq)[j].splitFix()
 }
 
 func (pq *nodeQueue) Swap(i, j int) {
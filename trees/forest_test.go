@@ -1,16 +1,202 @@
 package trees
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 )
 
 
 func TestSplit(t *testing.T) {
+	samples := []int{10, 15, 11, 12, 8, 3, 7}
+	expected := []int{0, 1, 0, 1, 0, 0, 1}
 	f := NewForest(2, 1, 0)
-	f.Train([]int{
-		10, 15, 11, 12, 8, 3, 7,
-	}, []int{
-		 0,  1,  0,  1, 0, 0, 1,
-	})
-	t.Error("Test run")
+	f.Train(samples, expected)
+
+	// The difference between the two samples in each frame separates the labels perfectly,
+	// so a single split should give a root with two leaves and no errors.
+	if nodes := f.DecisionNodes(); nodes != 3 {
+		t.Errorf("Expected 3 nodes, got %d:\n%v", nodes, f)
+	}
+	if errors := f.AverageErrors(); errors != 0 {
+		t.Errorf("Expected no training errors, got %v", errors)
+	}
+
+	// The first sample has no full frame, so wasn't trained on.
+	predictions := f.Classify(samples)
+	for i := 1; i < len(samples); i++ {
+		if predictions[i] != float64(expected[i]) {
+			t.Errorf("Sample %d: expected %d, classified as %v", i, expected[i], predictions[i])
+		}
+	}
+}
+
+func TestGiniSplitWithoutFixingErrors(t *testing.T) {
+	// Events in the middle: no single cutoff fixes any errors, but splitting at 80
+	// reduces the impurity and lets the next split at 90 separate them perfectly.
+	samples, expected := make([]int, 100), make([]int, 100)
+	for i := range samples {
+		samples[i] = i
+		if i >= 80 && i < 90 {
+			expected[i] = 1
+		}
+	}
+	f := NewForest(1, 1, 0)
+	f.SetCriterion(Gini)
+	f.Train(samples, expected)
+
+	if nodes := f.DecisionNodes(); nodes != 5 {
+		t.Errorf("Expected 5 nodes, got %d:\n%v", nodes, f)
+	}
+	if errors := f.AverageErrors(); errors != 0 {
+		t.Errorf("Expected no training errors, got %v", errors)
+	}
+}
+
+func TestHistogramSplit(t *testing.T) {
+	samples := []int{10, 15, 11, 12, 8, 3, 7}
+	expected := []int{0, 1, 0, 1, 0, 0, 1}
+	f := NewForest(2, 1, 0)
+	f.SetHistogramBins(16)
+	f.Train(samples, expected)
+
+	// The differences [5, -4, 1, -4, -5, 4] are still separated by a bin boundary.
+	if nodes := f.DecisionNodes(); nodes != 3 {
+		t.Errorf("Expected 3 nodes, got %d:\n%v", nodes, f)
+	}
+	if errors := f.AverageErrors(); errors != 0 {
+		t.Errorf("Expected no training errors, got %v", errors)
+	}
+}
+
+func TestTrainDeterministic(t *testing.T) {
+	samples, expected := syntheticData(2000)
+	var first string
+	for run := 0; run < 5; run++ {
+		f := NewForest(4, 5, 5)
+		f.Train(samples, expected)
+		if run == 0 {
+			first = f.String()
+		} else if got := f.String(); got != first {
+			t.Fatalf("Run %d trained a different forest:\n%s\nvs first:\n%s", run, got, first)
+		}
+	}
+}
+
+func TestOnSplit(t *testing.T) {
+	samples, expected := syntheticData(2000)
+	f := NewForest(4, 5, 5)
+	calls, lastNodes, lastErrors := 0, 0, 0
+	f.OnSplit = func(nodesCreated, leavesRemaining, totalErrors int) {
+		calls++
+		lastNodes, lastErrors = nodesCreated, totalErrors
+		if leavesRemaining < 0 {
+			t.Errorf("Negative leaves remaining: %d", leavesRemaining)
+		}
+	}
+	f.Train(samples, expected)
+
+	if calls == 0 || calls != (f.DecisionNodes()-5)/2 {
+		t.Errorf("Expected one call per split, got %d for %d nodes", calls, f.DecisionNodes())
+	}
+	if lastNodes != f.DecisionNodes() {
+		t.Errorf("Expected %d nodes created, got %d", f.DecisionNodes(), lastNodes)
+	}
+	if errors := int(math.Round(f.AverageErrors() * 5)); lastErrors != errors {
+		t.Errorf("Expected %d total errors, got %d", errors, lastErrors)
+	}
+}
+
+func TestTrainMultiSplitsOnSignalChannel(t *testing.T) {
+	// Only channel 1 has the events, the others are noise.
+	rng := rand.New(rand.NewSource(1))
+	n := 500
+	channels, expected := [][]int{make([]int, n), make([]int, n), make([]int, n)}, make([]int, n)
+	for i := 0; i < n; i++ {
+		expected[i] = rng.Intn(2)
+		channels[0][i] = rng.Intn(100)
+		channels[1][i] = expected[i]*100 + rng.Intn(10)
+		channels[2][i] = rng.Intn(100)
+	}
+	f := NewForest(2, 1, 0)
+	f.TrainMulti(channels, expected)
+
+	baseCount := NewFrameFeatures(2).FeatureCount()
+	root := f.roots[0]
+	if root.isLeaf || root.branchData.decideFeature/baseCount != 1 {
+		t.Fatalf("Expected the root to split on a channel 1 feature, got:\n%v", f)
+	}
+	if errors := f.AverageErrors(); errors != 0 {
+		t.Errorf("Expected no training errors, got %v", errors)
+	}
+	predictions := f.ClassifyMulti(channels)
+	for i := 1; i < n; i++ {
+		if predictions[i] != float64(expected[i]) {
+			t.Errorf("Sample %d: expected %d, classified as %v", i, expected[i], predictions[i])
+		}
+	}
+}
+
+func TestPartialFit(t *testing.T) {
+	// With a flat signal and no events at first, the tree is a single leaf that never predicts one.
+	f := NewForest(4, 1, 0)
+	f.Train(make([]int, 1000), make([]int, 1000))
+	if nodes := f.DecisionNodes(); nodes != 1 {
+		t.Fatalf("Expected a single leaf, got %d nodes:\n%v", nodes, f)
+	}
+
+	samples, expected := syntheticData(1000)
+	f.PartialFit(samples, expected)
+	if nodes := f.DecisionNodes(); nodes <= 1 {
+		t.Fatalf("Expected the new events to be split on, got %d nodes", nodes)
+	}
+	changed := false
+	for _, p := range f.Classify(samples) {
+		if p > 0 {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Errorf("Expected some new samples to be classified as events after PartialFit")
+	}
+}
+
+func TestPartialFitUntrained(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected PartialFit to panic on an untrained forest")
+		}
+	}()
+	samples, expected := syntheticData(100)
+	NewForest(4, 1, 0).PartialFit(samples, expected)
+}
+
+// syntheticData makes noisy samples where events are more likely after a rising edge.
+func syntheticData(n int) ([]int, []int) {
+	rng := rand.New(rand.NewSource(1))
+	samples, expected := make([]int, n), make([]int, n)
+	for i := range samples {
+		samples[i] = rng.Intn(100)
+		if i > 0 && samples[i]-samples[i-1] > 50 && rng.Float64() < 0.8 {
+			expected[i] = 1
+		}
+	}
+	return samples, expected
+}
+
+func BenchmarkForestTrain(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("samples=%d", size), func(b *testing.B) {
+			samples, expected := syntheticData(size)
+			var stats TrainStats
+			for i := 0; i < b.N; i++ {
+				f := NewForest(4, 10, 10)
+				f.Train(samples, expected)
+				stats = f.TrainStats()
+			}
+			b.ReportMetric(float64(stats.Nodes), "nodes")
+			b.ReportMetric(float64(stats.FramesProcessed), "frames")
+		})
+	}
 }
@@ -0,0 +1,85 @@
+package trees
+
+import (
+	"sync/atomic"
+)
+
+// binnedSplitReduction finds the best split like splitReduction, but only trying cutoffs at
+// the boundaries of histogramBins equal-width bins, so the frames never need sorting.
+func (n *node) binnedSplitReduction(f *Forest, feature int) splitDetails {
+	nFrames := len(n.inputs)
+	trueAbove, falseAbove := n.classWeights(f)
+	bestSplit := splitDetails{
+		-1, -1, false, n.misclassified, -1, -1,
+		f.criterion.nodeScore(n.misclassified, trueAbove, falseAbove),
+	}
+
+	atomic.AddInt64(&f.stats.FramesProcessed, int64(nFrames))
+	scores := make([]float64, nFrames, nFrames)
+	min, max := 0.0, 0.0
+	for i, frame := range n.inputs {
+		scores[i] = scoreForFrameAndFeature(f, frame, feature)
+		if i == 0 || scores[i] < min {
+			min = scores[i]
+		}
+		if i == 0 || scores[i] > max {
+			max = scores[i]
+		}
+	}
+	if nFrames == 0 || min == max {
+		// Every frame has the same value, so there's nothing to split.
+		return bestSplit
+	}
+
+	// Bin b holds values in [cutoffs[b], cutoffs[b + 1]), with the ends open.
+	bins := f.histogramBins
+	width := (max - min) / float64(bins)
+	cutoffs := make([]float64, bins, bins)
+	for b := range cutoffs {
+		cutoffs[b] = min + float64(b)*width
+	}
+	trueWeights := make([]float64, bins, bins)
+	falseWeights := make([]float64, bins, bins)
+	counts := make([]int, bins, bins)
+	for i, frame := range n.inputs {
+		// The division can be off by one at the edges, so fix it up against the cutoffs,
+		// which must agree with the score < cutoff test used when splitting.
+		b := int((scores[i] - min) / width)
+		if b >= bins {
+			b = bins - 1
+		}
+		for b > 0 && scores[i] < cutoffs[b] {
+			b--
+		}
+		for b < bins-1 && scores[i] >= cutoffs[b+1] {
+			b++
+		}
+
+		counts[b]++
+		if f.trainExpected[frame+f.frameSize-1] == 1 {
+			trueWeights[b] += f.frameWeight(frame)
+		} else {
+			falseWeights[b] += f.frameWeight(frame)
+		}
+	}
+
+	// Cutoffs are tried in increasing order, so ties go to the lowest.
+	trueBelow, falseBelow, countBelow := 0.0, 0.0, 0
+	for b := 1; b < bins; b++ {
+		trueBelow += trueWeights[b-1]
+		falseBelow += falseWeights[b-1]
+		trueAbove -= trueWeights[b-1]
+		falseAbove -= falseWeights[b-1]
+		countBelow += counts[b-1]
+
+		// Empty bins give the same split as the cutoff before, and both sides need enough frames.
+		if counts[b-1] == 0 || countBelow < f.minSamplesLeaf || nFrames-countBelow < f.minSamplesLeaf {
+			continue
+		}
+		candidate := scoreSplit(f, feature, cutoffs[b], trueBelow, falseBelow, trueAbove, falseAbove)
+		if candidate.score < bestSplit.score {
+			bestSplit = candidate
+		}
+	}
+	return bestSplit
+}
@@ -0,0 +1,108 @@
+package trees
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/padster/eego/ml"
+)
+
+// Portable JSON form of a trained forest, so it can be inspected outside of Go.
+
+// jsonForest is the top level JSON document for a forest.
+type jsonForest struct {
+	// Number of samples in each frame.
+	FrameSize int `json:"frameSize"`
+	// Number of features calculated per frame, and what they represent.
+	FeatureCount int      `json:"featureCount"`
+	FeatureNames []string `json:"featureNames"`
+	// One root per tree.
+	Trees []*jsonNode `json:"trees"`
+	// If standardizing, each channel's samples have its mean subtracted then are divided by
+	// its standard deviation before features are calculated.
+	ChannelMean []float64 `json:"channelMean,omitempty"`
+	ChannelStd  []float64 `json:"channelStd,omitempty"`
+	// If calibrated, the averaged leaf probability p maps to 1 / (1 + e^-(bias + slope * p)).
+	Calibration *ml.Logistic `json:"calibration,omitempty"`
+}
+
+// jsonNode is either a branch (feature + cutoff + children) or a leaf (label + probability).
+type jsonNode struct {
+	Leaf bool `json:"leaf"`
+
+	// Branch data: frames with feature < cutoff go to lower, otherwise highEq.
+	Feature *int      `json:"feature,omitempty"`
+	Cutoff  *float64  `json:"cutoff,omitempty"`
+	Lower   *jsonNode `json:"lower,omitempty"`
+	HighEq  *jsonNode `json:"highEq,omitempty"`
+
+	// Leaf data:
+	ClassifyAsTrue *bool    `json:"classifyAsTrue,omitempty"`
+	Probability    *float64 `json:"probability,omitempty"`
+
+	// Training details, available for both.
+	Frames        int     `json:"frames"`
+	Misclassified float64 `json:"misclassified"`
+}
+
+// MarshalJSON writes the forest as a nested tree of decisions, plus the metadata
+// required to recalculate the features for each frame.
+func (f *Forest) MarshalJSON() ([]byte, error) {
+	features := f.extractor.FeatureCount()
+	names := make([]string, features, features)
+	for i := range names {
+		names[i] = f.extractor.FeatureName(i)
+	}
+
+	trees := make([]*jsonNode, 0, len(f.roots))
+	for _, root := range f.roots {
+		if root == nil {
+			return nil, fmt.Errorf("forest must be trained before writing as JSON")
+		}
+		trees = append(trees, root.toJSON(f))
+	}
+
+	return json.Marshal(jsonForest{
+		FrameSize:    f.frameSize,
+		FeatureCount: features,
+		FeatureNames: names,
+		Trees:        trees,
+		ChannelMean:  f.channelMean,
+		ChannelStd:   f.channelStd,
+		Calibration:  f.calibration,
+	})
+}
+
+// toJSON converts the subtree rooted at this node into its JSON form.
+func (n *node) toJSON(f *Forest) *jsonNode {
+	result := &jsonNode{
+		Leaf:          n.isLeaf,
+		Frames:        len(n.inputs),
+		Misclassified: n.misclassified,
+	}
+	if n.isLeaf {
+		classifyAsTrue, probability := n.classifyAsTrue, n.trueFraction
+		result.ClassifyAsTrue = &classifyAsTrue
+		result.Probability = &probability
+	} else {
+		feature, cutoff := n.branchData.decideFeature, n.branchData.decideCutoff
+		result.Feature = &feature
+		result.Cutoff = &cutoff
+		result.Lower = n.branchData.lowerChild.toJSON(f)
+		result.HighEq = n.branchData.highEqChild.toJSON(f)
+	}
+	return result
+}
+
+// trueProbability is the fraction of frames reaching this node which were true.
+func (n *node) trueProbability(f *Forest) float64 {
+	total := f.inputsWeight(n.inputs)
+	if total == 0 {
+		if n.classifyAsTrue {
+			return 1.0
+		}
+		return 0.0
+	}
+	trueWeight, _ := n.classWeights(f)
+	return trueWeight / total
+}
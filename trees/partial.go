@@ -0,0 +1,120 @@
+package trees
+
+import (
+	"container/heap"
+	"time"
+)
+
+// PartialFit updates an already trained forest with more samples following on from the
+// training data, without retraining from scratch. The new frames (including those which
+// overlap the end of the previous samples) are added to every tree: each is passed down
+// the existing branches to a leaf, the counts along the way are updated, and then any leaf
+// whose best split now improves enough is split further, exactly as in Train.
+// Existing branches are never changed, so the trees can end up worse than full retraining.
+// Any calibration is removed, as it was fit to the old trees.
+// Weighted and multi-channel forests aren't supported.
+func (f *Forest) PartialFit(newSamples []int, newExpected []int) {
+	if f.trainSamples == nil {
+		panic("PartialFit requires the forest to have been trained first")
+	}
+	if f.trainWeights != nil || f.channelCount() != 1 {
+		panic("PartialFit doesn't support weighted or multi-channel training")
+	}
+	if len(newSamples) != len(newExpected) {
+		panic("PartialFit requires samples and expected values to be the same size")
+	}
+	startTime := time.Now()
+	f.stats = TrainStats{}
+	f.calibration = nil
+
+	// Copy rather than append in place, as the training data may be the caller's slices.
+	oldFrameCount := f.trainFrameCount
+	f.trainSamples = append(f.trainSamples[:len(f.trainSamples):len(f.trainSamples)], f.standardized(asFloats(newSamples))...)
+	f.trainExpected = append(f.trainExpected[:len(f.trainExpected):len(f.trainExpected)], newExpected...)
+	f.trainFrameCount = len(f.trainSamples) - f.frameSize + 1
+
+	// Only frames on the stride are used, see SetStride.
+	newFrames := []int{}
+	firstFrame := 0
+	if oldFrameCount > 0 {
+		firstFrame = (oldFrameCount + f.stride - 1) / f.stride * f.stride
+	}
+	for frame := firstFrame; frame < f.trainFrameCount; frame += f.stride {
+		newFrames = append(newFrames, frame)
+	}
+
+	for _, root := range f.roots {
+		root.addFrames(f, newFrames)
+	}
+	f.startProgress()
+
+	for i, root := range f.roots {
+		inBag := make([]bool, f.trainFrameCount, f.trainFrameCount)
+		copy(inBag, f.inBag[i])
+		for _, frame := range newFrames {
+			inBag[frame] = true
+		}
+		f.inBag[i] = inBag
+
+		leafQueue := make(nodeQueue, 0)
+		for _, leaf := range root.leaves() {
+			leaf.branchData = branchNode{-1, -1, nil, nil}
+			if leaf.misclassified > 0 && f.canSplit(leaf) {
+				leaf.precalcBestSplit(f)
+				if leaf.branchData.decideFeature != -1 {
+					heap.Push(&leafQueue, leaf)
+					f.trackQueued(1)
+				}
+			}
+		}
+		if f.growQueue(&leafQueue, startTime) {
+			f.stats.DeadlineHit = true
+		}
+	}
+	f.stats.Nodes = f.DecisionNodes()
+	f.stats.Duration = time.Since(startTime)
+}
+
+// addFrames passes new frames down the subtree from this node, adding them to the inputs of
+// each node they reach, and updating each node's counts.
+func (n *node) addFrames(f *Forest, frames []int) {
+	// Nodes share their inputs' backing array with their parent, so copy before adding.
+	n.inputs = append(n.inputs[:len(n.inputs):len(n.inputs)], frames...)
+
+	trueWeight, falseWeight := 0.0, 0.0
+	for _, frame := range n.inputs {
+		if f.trainExpected[frame+f.frameSize-1] == 1 {
+			trueWeight += f.frameWeight(frame)
+		} else {
+			falseWeight += f.frameWeight(frame)
+		}
+	}
+	n.classifyAsTrue = trueWeight > falseWeight
+	n.misclassified = trueWeight
+	if n.classifyAsTrue {
+		n.misclassified = falseWeight
+	}
+	n.trueFraction = n.trueProbability(f)
+
+	if n.isLeaf {
+		return
+	}
+	lower, upper := []int{}, []int{}
+	for _, frame := range frames {
+		if scoreForFrameAndFeature(f, frame, n.branchData.decideFeature) < n.branchData.decideCutoff {
+			lower = append(lower, frame)
+		} else {
+			upper = append(upper, frame)
+		}
+	}
+	n.branchData.lowerChild.addFrames(f, lower)
+	n.branchData.highEqChild.addFrames(f, upper)
+}
+
+// leaves returns all the leaf nodes in the subtree starting at this node.
+func (n *node) leaves() []*node {
+	if n.isLeaf {
+		return []*node{n}
+	}
+	return append(n.branchData.lowerChild.leaves(), n.branchData.highEqChild.leaves()...)
+}
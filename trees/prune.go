@@ -0,0 +1,51 @@
+package trees
+
+// Prune simplifies every tree by cost-complexity (weakest link) pruning, minimizing
+// training errors + alpha * leaves. The branch whose collapse into a leaf costs the fewest
+// extra errors per leaf removed is collapsed first, repeating while that cost is at most
+// alpha. An alpha of 0 only removes splits which don't reduce the errors at all.
+// This lets trees be trained deep, then pruned back to avoid overfitting.
+// Any calibration is removed, as it was fit to the unpruned trees. Panics if the forest
+// hasn't been trained.
+func (f *Forest) Prune(alpha float64) {
+	if alpha < 0 {
+		panic("Prune alpha must not be negative")
+	}
+	for _, root := range f.roots {
+		if root == nil {
+			panic("Prune requires the forest to have been trained first")
+		}
+	}
+	f.calibration = nil
+	for _, root := range f.roots {
+		for !root.isLeaf {
+			weakest, cost := root.weakestLink()
+			if cost > alpha {
+				break
+			}
+			weakest.isLeaf = true
+			weakest.branchData = branchNode{-1, -1, nil, nil}
+		}
+	}
+}
+
+// weakestLink finds the branch within this subtree which increases the errors least
+// per leaf removed when collapsed, returning it and that increase.
+func (n *node) weakestLink() (*node, float64) {
+	cost := (n.misclassified - n.totalErrors()) / float64(n.leafCount()-1)
+	weakest := n
+	for _, child := range []*node{n.branchData.lowerChild, n.branchData.highEqChild} {
+		if child.isLeaf {
+			continue
+		}
+		if childWeakest, childCost := child.weakestLink(); childCost < cost {
+			weakest, cost = childWeakest, childCost
+		}
+	}
+	return weakest, cost
+}
+
+// leafCount is the number of leaves in the subtree starting at this node.
+func (n *node) leafCount() int {
+	return (n.subtreeSize() + 1) / 2
+}
@@ -0,0 +1,67 @@
+package trees
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPruneToRoot(t *testing.T) {
+	samples, expected := syntheticData(2000)
+	f := NewForest(4, 5, 0)
+	f.Train(samples, expected)
+	if nodes := f.DecisionNodes(); nodes <= 5 {
+		t.Fatalf("Expected the trees to have split, got %d nodes", nodes)
+	}
+
+	// No split can cost more than every training frame, so every tree collapses to its root.
+	f.Prune(math.Inf(1))
+	if nodes := f.DecisionNodes(); nodes != 5 {
+		t.Errorf("Expected only the 5 roots, got %d nodes:\n%v", nodes, f)
+	}
+}
+
+func TestPruneZeroAlpha(t *testing.T) {
+	// Every split reduces the errors, so none are removed.
+	f := NewForest(2, 1, 0)
+	f.Train([]int{10, 15, 11, 12, 8, 3, 7}, []int{0, 1, 0, 1, 0, 0, 1})
+	f.Prune(0)
+	if nodes := f.DecisionNodes(); nodes != 3 {
+		t.Errorf("Expected 3 nodes, got %d:\n%v", nodes, f)
+	}
+	if errors := f.AverageErrors(); errors != 0 {
+		t.Errorf("Expected no training errors, got %v", errors)
+	}
+
+	// With only one level, the Gini split at 80 fixes no errors, so is removed.
+	samples, expected := make([]int, 100), make([]int, 100)
+	for i := range samples {
+		samples[i] = i
+		if i >= 80 && i < 90 {
+			expected[i] = 1
+		}
+	}
+	f = NewForest(1, 1, 0)
+	f.SetCriterion(Gini)
+	f.SetMaxDepth(1)
+	f.Train(samples, expected)
+	if nodes := f.DecisionNodes(); nodes != 3 {
+		t.Fatalf("Expected 3 nodes before pruning, got %d:\n%v", nodes, f)
+	}
+	before := f.AverageErrors()
+	f.Prune(0)
+	if nodes := f.DecisionNodes(); nodes != 1 {
+		t.Errorf("Expected only the root, got %d nodes:\n%v", nodes, f)
+	}
+	if errors := f.AverageErrors(); errors != before {
+		t.Errorf("Expected pruning to keep %v training errors, got %v", before, errors)
+	}
+}
+
+func TestPruneUntrained(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected Prune to panic on an untrained forest")
+		}
+	}()
+	NewForest(4, 5, 0).Prune(0)
+}
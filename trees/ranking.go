@@ -0,0 +1,103 @@
+package trees
+
+import (
+	"math"
+	"sort"
+)
+
+// Number of quantile bins each feature is discretized into when calculating mutual information.
+const miBins = 10
+
+// FeatureScore is how informative a single feature is about the expected labels.
+type FeatureScore struct {
+	Feature int
+	Name    string
+	Score   float64
+}
+
+// RankFeaturesByMI scores every feature by its mutual information (in nats) with the
+// expected labels, over the same frames that Train would create, returning the most
+// informative first. This is a cheap filter to pick features before training.
+func RankFeaturesByMI(samples []int, expected []int, extractor FeatureExtractor) []FeatureScore {
+	frameSize := extractor.FrameSize()
+	frameCount := len(samples) - frameSize + 1
+	if frameCount <= 0 {
+		panic("Not enough samples to form a single frame")
+	}
+
+	floatSamples := asFloats(samples)
+	labels := make([]int, frameCount, frameCount)
+	for i := range labels {
+		labels[i] = expected[i+frameSize-1]
+	}
+
+	scores := make([]FeatureScore, extractor.FeatureCount())
+	values := make([]float64, frameCount, frameCount)
+	for feature := range scores {
+		for i := range values {
+			values[i] = extractor.Feature(floatSamples[i:i+frameSize], feature)
+		}
+		scores[feature] = FeatureScore{
+			feature,
+			extractor.FeatureName(feature),
+			mutualInformation(quantileBins(values, miBins), labels),
+		}
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+// TopFeatures returns the feature indexes of the k highest scoring features.
+func TopFeatures(scores []FeatureScore, k int) []int {
+	if k > len(scores) {
+		k = len(scores)
+	}
+	top := make([]int, k, k)
+	for i := range top {
+		top[i] = scores[i].Feature
+	}
+	return top
+}
+
+// quantileBins assigns each value to one of (up to) the given number of equally populated bins.
+// Equal values always share the same bin.
+func quantileBins(values []float64, bins int) []int {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	cutoffs := make([]float64, 0, bins-1)
+	for b := 1; b < bins; b++ {
+		cutoffs = append(cutoffs, sorted[b*len(sorted)/bins])
+	}
+
+	result := make([]int, len(values), len(values))
+	for i, v := range values {
+		result[i] = sort.Search(len(cutoffs), func(c int) bool {
+			return cutoffs[c] > v
+		})
+	}
+	return result
+}
+
+// mutualInformation calculates I(X; Y) between discrete bins and 0/1 labels.
+func mutualInformation(bins []int, labels []int) float64 {
+	n := float64(len(bins))
+	joint := map[[2]int]float64{}
+	binCounts, labelCounts := map[int]float64{}, map[int]float64{}
+	for i, b := range bins {
+		joint[[2]int{b, labels[i]}]++
+		binCounts[b]++
+		labelCounts[labels[i]]++
+	}
+
+	mi := 0.0
+	for key, count := range joint {
+		pJoint := count / n
+		pBin, pLabel := binCounts[key[0]]/n, labelCounts[key[1]]/n
+		mi += pJoint * math.Log(pJoint/(pBin*pLabel))
+	}
+	return mi
+}
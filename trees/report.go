@@ -0,0 +1,75 @@
+package trees
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitInfo describes a single split chosen while training.
+type SplitInfo struct {
+	// Feature and cutoff of the split, frames with feature < cutoff go to the lower child.
+	Feature int
+	Cutoff  float64
+	// Information gain of the split, in bits per frame: the entropy of the node minus
+	// the average entropy of its children, weighted by the fraction of frames in each.
+	Gain float64
+	// How many branches are above the split node, 0 for the root.
+	Depth int
+}
+
+// SplitReport returns every split made during the last call to Train, grouped by tree in
+// the order the splits were made. This shows which features the trees find informative.
+func (f *Forest) SplitReport() []SplitInfo {
+	report := []SplitInfo{}
+	for _, splits := range f.splits {
+		report = append(report, splits...)
+	}
+	return report
+}
+
+// splitInfo describes the split of a branch node into its two children.
+func (n *node) splitInfo(f *Forest) SplitInfo {
+	lower, upper := n.branchData.lowerChild, n.branchData.highEqChild
+	gain := 0.0
+	if total := f.inputsWeight(n.inputs); total > 0 {
+		gain = (entropyOf(f, n) - entropyOf(f, lower) - entropyOf(f, upper)) / total
+	}
+	return SplitInfo{n.branchData.decideFeature, n.branchData.decideCutoff, gain, n.depth}
+}
+
+// entropyOf is the entropy of a node's frames, weighted by the frame total.
+func entropyOf(f *Forest, n *node) float64 {
+	trueWeight, falseWeight := n.classWeights(f)
+	return Entropy.impurity(trueWeight, falseWeight)
+}
+
+// String prints each tree as an indented structure, e.g.
+//
+//	tree 0:
+//	  mean < 12.5
+//	    classify=false (mis=3)
+//	    classify=true (mis=0)
+//
+// with the lower child of each branch printed before the higher or equal child.
+func (f *Forest) String() string {
+	var b strings.Builder
+	for i, root := range f.roots {
+		fmt.Fprintf(&b, "tree %d:\n", i)
+		if root != nil {
+			root.writeTo(&b, f, 1)
+		}
+	}
+	return b.String()
+}
+
+// writeTo prints the subtree starting at this node, indented by the given depth.
+func (n *node) writeTo(b *strings.Builder, f *Forest, indent int) {
+	b.WriteString(strings.Repeat("  ", indent))
+	if n.isLeaf {
+		fmt.Fprintf(b, "classify=%v (mis=%g)\n", n.classifyAsTrue, n.misclassified)
+		return
+	}
+	fmt.Fprintf(b, "%s < %g\n", f.extractor.FeatureName(n.branchData.decideFeature), n.branchData.decideCutoff)
+	n.branchData.lowerChild.writeTo(b, f, indent+1)
+	n.branchData.highEqChild.writeTo(b, f, indent+1)
+}
@@ -0,0 +1,53 @@
+package trees
+
+import (
+	"github.com/padster/eego/util"
+)
+
+// SetStandardizeChannels makes Train rescale each channel to zero mean and unit standard
+// deviation before calculating features, so that with TrainMulti, channels recorded with a
+// large amplitude don't dominate the splits just because their values span a wider range.
+// The mean and standard deviation of each channel's training samples are kept, and the
+// same transform is applied to the samples given to Classify and PartialFit. The zero-padding
+// before the first full frame is then padding with each channel's mean. Off by default.
+func (f *Forest) SetStandardizeChannels(standardize bool) {
+	f.standardize = standardize
+}
+
+// fitChannelStats sets the mean and standard deviation of each channel of the interleaved
+// samples, or clears them if the forest doesn't standardize.
+func (f *Forest) fitChannelStats(samples []float64) {
+	if !f.standardize {
+		f.channelMean, f.channelStd = nil, nil
+		return
+	}
+	channels := f.channelCount()
+	stats := make([]util.RunningStats, channels, channels)
+	for i, v := range samples {
+		stats[i%channels].Push(v)
+	}
+	f.channelMean = make([]float64, channels, channels)
+	f.channelStd = make([]float64, channels, channels)
+	for c := range stats {
+		f.channelMean[c] = stats[c].Mean()
+		f.channelStd[c] = stats[c].StdDev()
+		// Constant channels become all 0 rather than NaN.
+		if f.channelStd[c] == 0 {
+			f.channelStd[c] = 1
+		}
+	}
+}
+
+// standardized returns the interleaved samples with each channel rescaled by the stats from
+// training, or the samples unchanged if the forest doesn't standardize.
+func (f *Forest) standardized(samples []float64) []float64 {
+	if f.channelMean == nil {
+		return samples
+	}
+	channels := len(f.channelMean)
+	result := make([]float64, len(samples), len(samples))
+	for i, v := range samples {
+		result[i] = (v - f.channelMean[i%channels]) / f.channelStd[i%channels]
+	}
+	return result
+}
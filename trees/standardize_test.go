@@ -0,0 +1,48 @@
+package trees
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStandardizeChannels(t *testing.T) {
+	samples, expected := syntheticData(1000)
+	loud, constant := make([]int, len(samples)), make([]int, len(samples))
+	for i, v := range samples {
+		loud[i] = 1000*v + 50000
+		constant[i] = 7
+	}
+	f := NewForest(4, 1, 0)
+	f.SetStandardizeChannels(true)
+	f.TrainMulti([][]int{samples, loud, constant}, expected)
+
+	for c, channel := range f.trainChannels {
+		sum, sumSq := 0.0, 0.0
+		for _, v := range channel {
+			if math.IsNaN(v) {
+				t.Fatalf("Channel %d has NaN after standardizing", c)
+			}
+			sum += v
+			sumSq += v * v
+		}
+		mean := sum / float64(len(channel))
+		variance := sumSq/float64(len(channel)) - mean*mean
+		if math.Abs(mean) > 1e-9 {
+			t.Errorf("Channel %d: expected mean 0, got %v", c, mean)
+		}
+		// The constant channel has no variance to scale, so stays all 0.
+		expectedVariance := 1.0
+		if c == 2 {
+			expectedVariance = 0
+		}
+		if math.Abs(variance-expectedVariance) > 1e-9 {
+			t.Errorf("Channel %d: expected variance %v, got %v", c, expectedVariance, variance)
+		}
+	}
+
+	for i, p := range f.ClassifyMulti([][]int{samples, loud, constant}) {
+		if math.IsNaN(p) {
+			t.Fatalf("Sample %d classified as NaN", i)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package trees
+
+// ClassifyStream classifies a live stream of samples, e.g. from a serial device, emitting
+// one probability per incoming sample, exactly as Classify would for the whole stream: each
+// uses the frame of the last N samples, zero-padded until N have arrived. The returned
+// channel is closed once in is closed. The forest mustn't be retrained while streaming.
+func (f *Forest) ClassifyStream(in <-chan float64) <-chan float64 {
+	if f.channelCount() != 1 {
+		panic("Forest has multi-channel features, ClassifyStream only supports one channel")
+	}
+	out := make(chan float64)
+	go func() {
+		defer close(out)
+		// Ring buffer of the last frameSize samples, the oldest at index next.
+		ring := make([]float64, f.frameSize, f.frameSize)
+		frame := make([]float64, f.frameSize, f.frameSize)
+		next := 0
+		for sample := range in {
+			if f.channelMean != nil {
+				sample = (sample - f.channelMean[0]) / f.channelStd[0]
+			}
+			ring[next] = sample
+			next = (next + 1) % f.frameSize
+			n := copy(frame, ring[next:])
+			copy(frame[n:], ring[:next])
+			out <- f.probability(frame)
+		}
+	}()
+	return out
+}
@@ -0,0 +1,36 @@
+package trees
+
+import (
+	"testing"
+)
+
+func TestClassifyStreamMatchesClassify(t *testing.T) {
+	samples, expected := syntheticData(1000)
+	for _, standardize := range []bool{false, true} {
+		f := NewForest(4, 5, 5)
+		f.SetStandardizeChannels(standardize)
+		f.Train(samples, expected)
+		batch := f.Classify(samples)
+
+		in := make(chan float64)
+		go func() {
+			for _, sample := range samples {
+				in <- float64(sample)
+			}
+			close(in)
+		}()
+		streamed := []float64{}
+		for p := range f.ClassifyStream(in) {
+			streamed = append(streamed, p)
+		}
+
+		if len(streamed) != len(batch) {
+			t.Fatalf("Expected %d streamed probabilities, got %d", len(batch), len(streamed))
+		}
+		for i := range batch {
+			if streamed[i] != batch[i] {
+				t.Errorf("Standardize %v, sample %d: streamed %v, but Classify gave %v", standardize, i, streamed[i], batch[i])
+			}
+		}
+	}
+}
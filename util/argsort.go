@@ -0,0 +1,33 @@
+package util
+
+import (
+	"sort"
+)
+
+// Argsort returns the indexes that would sort values ascending, so values[result[0]] is the
+// smallest. Equal values keep their original relative order. values itself is unchanged.
+func Argsort(values []float64) []int {
+	order := identity(len(values))
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	return order
+}
+
+// ArgsortInt is Argsort for int values.
+func ArgsortInt(values []int) []int {
+	order := identity(len(values))
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	return order
+}
+
+// identity returns [0, 1, ..., n-1]
+func identity(n int) []int {
+	result := make([]int, n, n)
+	for i := range result {
+		result[i] = i
+	}
+	return result
+}
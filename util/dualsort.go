@@ -1,65 +1,65 @@
 package util
 
 import (
-	"math"
+	"cmp"
 )
 
-// Multiple different data types of (T1, T2) dual arrays, which can be sorted by the 
-// first element, with tie-breaker by the second.
-// UUUGGH, go missing templates/generics.
-
-// DualSortII allows to sort (int, int) pairs.
-type DualSortII struct {
-	V1 []int
-	V2 []int
+// DualSort sorts any (key, value) pairs by key, carrying the values along.
+// Values don't take part in the ordering, so use sort.Stable to keep tied keys in
+// their original order.
+type DualSort[K cmp.Ordered, V any] struct {
+	Keys []K
+	Vals []V
 }
-func (vs DualSortII) Len() int {
-	return len(vs.V1)
+func (vs DualSort[K, V]) Len() int {
+	return len(vs.Keys)
 }
-func (vs DualSortII) Less(i, j int) bool {
-	return vs.V1[i] < vs.V1[j] || ((vs.V1[i] == vs.V1[j]) && (vs.V2[i] < vs.V2[j]))
+func (vs DualSort[K, V]) Less(i, j int) bool {
+	return cmp.Less(vs.Keys[i], vs.Keys[j])
 }
-func (vs DualSortII) Swap(i, j int) {
-	vs.V1[i], vs.V1[j] = vs.V1[j], vs.V1[i]
-	vs.V2[i], vs.V2[j] = vs.V2[j], vs.V2[i]
+func (vs DualSort[K, V]) Swap(i, j int) {
+	vs.Keys[i], vs.Keys[j] = vs.Keys[j], vs.Keys[i]
+	vs.Vals[i], vs.Vals[j] = vs.Vals[j], vs.Vals[i]
 }
 
+// Specific data types of (T1, T2) dual arrays, which are sorted by the first element,
+// with tie-breaker by the second. Unlike DualSort, these order by both elements, and
+// the float keyed versions can treat Fpeq-close keys as ties unless Exact is set.
+
+// DualSortII allows to sort (int, int) pairs.
+type DualSortII = dualSortTied[int, int]
+
+// DualSortIF allows to sort (int, float) pairs.
+type DualSortIF = dualSortTied[int, float64]
+
 // DualSortFF allows to sort (float, float) pairs.
-type DualSortFF struct {
-	V1 []float64
-	V2 []float64
-}
-func (vs DualSortFF) Len() int {
-	return len(vs.V1)
-}
-func (vs DualSortFF) Less(i, j int) bool {
-	return vs.V1[i] < vs.V1[j] || (Fpeq(vs.V1[i], vs.V1[j]) && vs.V2[i] < vs.V2[j])
-}
-func (vs DualSortFF) Swap(i, j int) {
-	vs.V1[i], vs.V1[j] = vs.V1[j], vs.V1[i]
-	vs.V2[i], vs.V2[j] = vs.V2[j], vs.V2[i]
-}
+type DualSortFF = dualSortTied[float64, float64]
 
 // DualSortFI allows you to sort (float, int) pairs.
-type DualSortFI struct {
-	V1 []float64
-	V2 []int
+type DualSortFI = dualSortTied[float64, int]
+
+// dualSortTied is the shared implementation of the specific dual sorts, using DualSort to
+// move the pairs, but breaking ties on V1 by V2.
+type dualSortTied[K cmp.Ordered, V cmp.Ordered] struct {
+	V1 []K
+	V2 []V
+	// Exact only treats identical float V1 values as ties, rather than Fpeq-close ones.
+	Exact bool
 }
-func (vs DualSortFI) Len() int {
-	return len(vs.V1)
+func (vs dualSortTied[K, V]) Len() int {
+	return DualSort[K, V]{vs.V1, vs.V2}.Len()
 }
-func (vs DualSortFI) Less(i, j int) bool {
-	return vs.V1[i] < vs.V1[j] || (Fpeq(vs.V1[i], vs.V1[j]) && vs.V2[i] < vs.V2[j])
+func (vs dualSortTied[K, V]) Less(i, j int) bool {
+	return vs.V1[i] < vs.V1[j] || (vs.tied(i, j) && vs.V2[i] < vs.V2[j])
 }
-func (vs DualSortFI) Swap(i, j int) {
-	vs.V1[i], vs.V1[j] = vs.V1[j], vs.V1[i]
-	vs.V2[i], vs.V2[j] = vs.V2[j], vs.V2[i]
+func (vs dualSortTied[K, V]) Swap(i, j int) {
+	DualSort[K, V]{vs.V1, vs.V2}.Swap(i, j)
 }
 
-
-// Returns if a and b are 'equal' for the floating point definition
-func Fpeq(a float64, b float64) bool {
-	// TODO(padster): Move outside dualsort.go
-	rtol, atol := 1e-5, 1e-8
-	return math.Abs(a-b) < atol+rtol*math.Abs(b)
+// tied returns whether the keys at i and j should fall back to the tie-breaker.
+func (vs dualSortTied[K, V]) tied(i, j int) bool {
+	if a, ok := any(vs.V1[i]).(float64); ok && !vs.Exact {
+		return Fpeq(a, any(vs.V1[j]).(float64))
+	}
+	return vs.V1[i] == vs.V1[j]
 }
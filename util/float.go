@@ -0,0 +1,22 @@
+package util
+
+import (
+	"math"
+)
+
+// Default tolerances used by Fpeq, matching numpy.isclose.
+const (
+	DefaultRtol = 1e-5
+	DefaultAtol = 1e-8
+)
+
+// Returns if a and b are 'equal' for the floating point definition
+func Fpeq(a float64, b float64) bool {
+	return FpeqTol(a, b, DefaultRtol, DefaultAtol)
+}
+
+// FpeqTol returns if a and b are within atol + rtol * |b| of each other.
+// Note this is asymmetric, b is treated as the reference value.
+func FpeqTol(a float64, b float64, rtol float64, atol float64) bool {
+	return math.Abs(a-b) < atol+rtol*math.Abs(b)
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"math"
+)
+
+// RunningStats keeps the mean and variance of a stream of values, updated one at a time by
+// Welford's algorithm. Unlike summing values and their squares, this doesn't lose precision
+// when the values are large compared to their spread. The zero value is ready to use.
+type RunningStats struct {
+	count int
+	mean  float64
+	// Sum of squared differences from the current mean.
+	m2 float64
+}
+
+// Push adds a value to the statistics.
+func (rs *RunningStats) Push(x float64) {
+	rs.count++
+	delta := x - rs.mean
+	rs.mean += delta / float64(rs.count)
+	rs.m2 += delta * (x - rs.mean)
+}
+
+// Count is how many values have been pushed.
+func (rs *RunningStats) Count() int {
+	return rs.count
+}
+
+// Mean of the values pushed, 0 if there are none.
+func (rs *RunningStats) Mean() float64 {
+	return rs.mean
+}
+
+// Variance is the population variance of the values pushed, 0 if there are none.
+func (rs *RunningStats) Variance() float64 {
+	if rs.count == 0 {
+		return 0
+	}
+	return rs.m2 / float64(rs.count)
+}
+
+// StdDev is the population standard deviation of the values pushed, 0 if there are none.
+func (rs *RunningStats) StdDev() float64 {
+	return math.Sqrt(rs.Variance())
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestRunningStats(t *testing.T) {
+	// Large offset with a small spread, where the sum of squares approach loses everything.
+	rs := RunningStats{}
+	for _, v := range []float64{4, 7, 13, 16} {
+		rs.Push(1e9 + v)
+	}
+	if rs.Count() != 4 {
+		t.Errorf("Expected 4 values, got %d", rs.Count())
+	}
+	if !Fpeq(rs.Mean(), 1e9+10) {
+		t.Errorf("Expected mean 1e9 + 10, got %f", rs.Mean())
+	}
+	if !Fpeq(rs.Variance(), 22.5) {
+		t.Errorf("Expected variance 22.5, got %f", rs.Variance())
+	}
+	if !Fpeq(rs.StdDev(), 4.743416490252569) {
+		t.Errorf("Expected std dev sqrt(22.5), got %f", rs.StdDev())
+	}
+
+	empty := RunningStats{}
+	if empty.Mean() != 0 || empty.Variance() != 0 {
+		t.Errorf("Expected zero stats with no values, got %f, %f", empty.Mean(), empty.Variance())
+	}
+}